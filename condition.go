@@ -0,0 +1,223 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/expr-lang/expr/vm"
+)
+
+type ConditionOp string
+
+const (
+	ConditionOpEq        ConditionOp = "eq"
+	ConditionOpNe        ConditionOp = "ne"
+	ConditionOpExists    ConditionOp = "exists"
+	ConditionOpNotExists ConditionOp = "not_exists"
+	ConditionOpGt        ConditionOp = "gt"
+	ConditionOpGte       ConditionOp = "gte"
+	ConditionOpLt        ConditionOp = "lt"
+	ConditionOpLte       ConditionOp = "lte"
+	ConditionOpRegex     ConditionOp = "regex"
+)
+
+// Condition is evaluated against a HandleRes.
+// The Path is a JSONPath-style path starts with $.data, $.meta, $.status or $.message,
+// e.g. $.data.user.tags[0], the $.status is the string of the HandleStatus like in the Expr, e.g. "ok".
+// The ConditionOpExists treats a null value as not existing.
+// A Condition with All or Any combines the sub conditions, the Path, Op and Value are ignored.
+// A Condition with Expr is a boolean expression of data, meta, status and message,
+// e.g. data.user.age >= 18 && meta.kind in ["a", "b"], the Path, Op and Value are ignored.
+type Condition struct {
+	Path  string      `json:"path,omitempty"`
+	Op    ConditionOp `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	Expr  string      `json:"expr,omitempty"`
+
+	All []Condition `json:"all,omitempty"` // true if all of them are true
+	Any []Condition `json:"any,omitempty"` // true if any of them is true
+
+	compiled bool
+	path     dataPath
+	regexp   *regexp.Regexp
+	program  *vm.Program
+}
+
+// Compile parses the Path, the regexp Value and the Expr of the Condition and its sub conditions,
+// the Conditions of a Line created by conf are compiled already.
+func (c *Condition) Compile() error {
+	return c.compile("condition")
+}
+
+// compile parses the Path and the regexp Value.
+// The returned error is prefixed with the given path of the Condition.
+func (c *Condition) compile(path string) error {
+	if c.All != nil || c.Any != nil {
+		for i := range c.All {
+			if err := c.All[i].compile(fmt.Sprintf("%s.all[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		for i := range c.Any {
+			if err := c.Any[i].compile(fmt.Sprintf("%s.any[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if c.Expr != "" {
+		program, err := compileExpr(c.Expr, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		c.program = program
+		c.compiled = true
+		return nil
+	}
+
+	p, err := parsePath(c.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	c.path = p
+
+	switch c.Op {
+	case ConditionOpEq, ConditionOpNe, ConditionOpExists, ConditionOpNotExists,
+		ConditionOpGt, ConditionOpGte, ConditionOpLt, ConditionOpLte:
+	case ConditionOpRegex:
+		expr, ok := c.Value.(string)
+		if !ok {
+			return fmt.Errorf("%s: %w: value must be a string", path, ErrConditionInvalidRegexp)
+		}
+		if c.regexp, err = regexp.Compile(expr); err != nil {
+			return fmt.Errorf("%s: %w: %v", path, ErrConditionInvalidRegexp, err)
+		}
+	default:
+		return fmt.Errorf("%s: %w: %s", path, ErrConditionUnknownOp, c.Op)
+	}
+	c.compiled = true
+	return nil
+}
+
+// Match evaluates the Condition against the res.
+// An uncompiled Condition is compiled on every call, it never matches if it is invalid.
+func (c Condition) Match(res *HandleRes) bool {
+	if c.All != nil || c.Any != nil {
+		for _, sub := range c.All {
+			if !sub.Match(res) {
+				return false
+			}
+		}
+		for _, sub := range c.Any {
+			if sub.Match(res) {
+				return true
+			}
+		}
+		return len(c.Any) == 0
+	}
+	if !c.compiled && c.compile("condition") != nil {
+		return false
+	}
+	if c.Expr != "" {
+		out, err := runExpr(c.program, res)
+		match, ok := out.(bool)
+		return err == nil && ok && match
+	}
+
+	root := map[string]interface{}{}
+	if res != nil {
+		root["data"] = res.Data
+		root["meta"] = res.Meta
+		root["status"] = res.Status.String()
+		root["message"] = res.Message
+	}
+	v, found := c.path.lookup(root)
+
+	switch c.Op {
+	case ConditionOpExists:
+		return found && v != nil
+	case ConditionOpNotExists:
+		return !found || v == nil
+	case ConditionOpEq:
+		return found && equal(v, c.Value)
+	case ConditionOpNe:
+		return !found || !equal(v, c.Value)
+	case ConditionOpGt, ConditionOpGte, ConditionOpLt, ConditionOpLte:
+		if !found {
+			return false
+		}
+		cmp, ok := compare(v, c.Value)
+		if !ok {
+			return false
+		}
+		switch c.Op {
+		case ConditionOpGt:
+			return cmp > 0
+		case ConditionOpGte:
+			return cmp >= 0
+		case ConditionOpLt:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	case ConditionOpRegex:
+		s, ok := v.(string)
+		return found && ok && c.regexp != nil && c.regexp.MatchString(s)
+	}
+	return false
+}
+
+// toFloat64 converts the numeric v into float64.
+func toFloat64(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// equal compares the a and b, numbers are compared by their float64 values.
+func equal(a, b interface{}) bool {
+	if af, ok := toFloat64(a); ok {
+		bf, ok := toFloat64(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare compares two numbers or two strings, returns false if they are not comparable.
+func compare(a, b interface{}) (int, bool) {
+	if af, ok := toFloat64(a); ok {
+		bf, ok := toFloat64(b)
+		switch {
+		case !ok:
+			return 0, false
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	as, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	bs, ok := b.(string)
+	switch {
+	case !ok:
+		return 0, false
+	case as < bs:
+		return -1, true
+	case as > bs:
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCondition_compile(t *testing.T) {
+	tt := []struct {
+		caseName string
+		cond     Condition
+		err      error
+	}{
+		{
+			caseName: "invalid path",
+			cond:     Condition{Path: "data.name", Op: ConditionOpExists},
+			err:      ErrInvalidPath,
+		},
+		{
+			caseName: "unknown op",
+			cond:     Condition{Path: "$.data.name", Op: "like"},
+			err:      ErrConditionUnknownOp,
+		},
+		{
+			caseName: "non-string regexp",
+			cond:     Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: 1},
+			err:      ErrConditionInvalidRegexp,
+		},
+		{
+			caseName: "invalid regexp",
+			cond:     Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: "("},
+			err:      ErrConditionInvalidRegexp,
+		},
+		{
+			caseName: "invalid sub condition",
+			cond:     Condition{Any: []Condition{{Path: "$.data", Op: "like"}}},
+			err:      ErrConditionUnknownOp,
+		},
+		{
+			caseName: "normal",
+			cond:     Condition{All: []Condition{{Path: "$.data", Op: ConditionOpRegex, Value: "^a"}}},
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if err := item.cond.compile("when"); !errors.Is(err, item.err) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+		})
+	}
+}
+
+func TestCondition_Match(t *testing.T) {
+	res := &HandleRes{
+		Status: HandleStatusOK,
+		Meta:   map[string]interface{}{"tenant": "acme"},
+		Data: map[string]interface{}{
+			"name":  "foo",
+			"age":   float64(18),
+			"score": 90,
+		},
+	}
+
+	tt := []struct {
+		caseName string
+		cond     Condition
+		match    bool
+	}{
+		{caseName: "eq", cond: Condition{Path: "$.data.name", Op: ConditionOpEq, Value: "foo"}, match: true},
+		{caseName: "eq number", cond: Condition{Path: "$.data.score", Op: ConditionOpEq, Value: float64(90)}, match: true},
+		{caseName: "eq not found", cond: Condition{Path: "$.data.none", Op: ConditionOpEq, Value: nil}},
+		{caseName: "ne", cond: Condition{Path: "$.meta.tenant", Op: ConditionOpNe, Value: "acme"}},
+		{caseName: "exists", cond: Condition{Path: "$.meta.tenant", Op: ConditionOpExists}, match: true},
+		{caseName: "not exists", cond: Condition{Path: "$.meta.user", Op: ConditionOpNotExists}, match: true},
+		{caseName: "gt", cond: Condition{Path: "$.data.age", Op: ConditionOpGt, Value: 18}},
+		{caseName: "gte", cond: Condition{Path: "$.data.age", Op: ConditionOpGte, Value: 18}, match: true},
+		{caseName: "lt string", cond: Condition{Path: "$.data.name", Op: ConditionOpLt, Value: "goo"}, match: true},
+		{caseName: "lte not comparable", cond: Condition{Path: "$.data.name", Op: ConditionOpLte, Value: 1}},
+		{caseName: "status", cond: Condition{Path: "$.status", Op: ConditionOpEq, Value: "ok"}, match: true},
+		{caseName: "status not number", cond: Condition{Path: "$.status", Op: ConditionOpEq, Value: HandleStatusOK}},
+		{caseName: "regex", cond: Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: "^f"}, match: true},
+		{caseName: "regex non-string", cond: Condition{Path: "$.data.age", Op: ConditionOpRegex, Value: "1"}},
+		{
+			caseName: "all",
+			cond: Condition{All: []Condition{
+				{Path: "$.data.name", Op: ConditionOpEq, Value: "foo"},
+				{Path: "$.data.age", Op: ConditionOpLt, Value: 18},
+			}},
+		},
+		{
+			caseName: "any",
+			cond: Condition{Any: []Condition{
+				{Path: "$.data.name", Op: ConditionOpEq, Value: "bar"},
+				{Path: "$.data.age", Op: ConditionOpLte, Value: 18},
+			}},
+			match: true,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if err := item.cond.compile("when"); err != nil {
+				t.Fatal(err)
+			}
+			if match := item.cond.Match(res); match != item.match {
+				t.Errorf("match: want=%v, got=%v", item.match, match)
+			}
+		})
+	}
+}
+
+func TestCondition_Match_Uncompiled(t *testing.T) {
+	res := &HandleRes{Data: map[string]interface{}{"name": "foo"}}
+	tt := []struct {
+		caseName string
+		cond     Condition
+		match    bool
+	}{
+		{caseName: "exists", cond: Condition{Path: "$.data.missing", Op: ConditionOpExists}},
+		{caseName: "not exists", cond: Condition{Path: "$.data.missing", Op: ConditionOpNotExists}, match: true},
+		{caseName: "regex", cond: Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: "^f"}, match: true},
+		{caseName: "expr", cond: Condition{Expr: `data.name == "foo"`}, match: true},
+		{caseName: "sub", cond: Condition{All: []Condition{{Path: "$.data.name", Op: ConditionOpEq, Value: "foo"}}}, match: true},
+		{caseName: "invalid path", cond: Condition{Path: "data", Op: ConditionOpNotExists}},
+		{caseName: "invalid op", cond: Condition{Path: "$.data.missing", Op: "unknown"}},
+		{caseName: "invalid regexp", cond: Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: "("}},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if match := item.cond.Match(res); match != item.match {
+				t.Errorf("match: want=%v, got=%v", item.match, match)
+			}
+		})
+	}
+}
+
+func TestCondition_Match_Status(t *testing.T) {
+	conf := `{"path": "$.status", "op": "eq", "value": "ok"}`
+	var cond Condition
+	if err := json.Unmarshal([]byte(conf), &cond); err != nil {
+		t.Fatal(err)
+	}
+	exprCond := Condition{Expr: `status == "ok"`}
+	for _, status := range []HandleStatus{HandleStatusOK, HandleStatusTimeout, HandleStatusFailed} {
+		res := &HandleRes{Status: status}
+		if match, exprMatch := cond.Match(res), exprCond.Match(res); match != (status == HandleStatusOK) || match != exprMatch {
+			t.Errorf("%v: want=%v, got path=%v, expr=%v", status, status == HandleStatusOK, match, exprMatch)
+		}
+	}
+}
+
+func TestCondition_Compile(t *testing.T) {
+	cond := Condition{Any: []Condition{{Path: "$.data.name", Op: ConditionOpRegex, Value: "("}}}
+	if err := cond.Compile(); !errors.Is(err, ErrConditionInvalidRegexp) {
+		t.Errorf("err: want=%v, got=%v", ErrConditionInvalidRegexp, err)
+	}
+
+	cond = Condition{Path: "$.data.name", Op: ConditionOpRegex, Value: "^f"}
+	if err := cond.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if !cond.Match(&HandleRes{Data: map[string]interface{}{"name": "foo"}}) {
+		t.Error("match: want=true, got=false")
+	}
+}
@@ -0,0 +1,349 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// groupConf used to parse the conf of a line Pipe.
+type groupConf struct {
+	Desc  string            `json:"desc"`
+	Pipes []json.RawMessage `json:"pipes"`
+}
+
+// parallelConf used to parse the conf of a parallel Pipe.
+type parallelConf struct {
+	Desc      string            `json:"desc"`
+	Pipes     []json.RawMessage `json:"pipes"`
+	Merge     string            `json:"merge"`      // name of a Merger, empty means MergerList
+	MetaMerge MetaMerge         `json:"meta_merge"` // empty means MetaMergeLastWins
+	Mode      ParallelMode      `json:"mode"`       // empty means ParallelModeAll
+	Quorum    int               `json:"quorum"`     // used by ParallelModeQuorum
+
+	MaxConcurrency int `json:"max_concurrency"` // 0 means no limit
+}
+
+// switchConf used to parse the conf of a switch Pipe.
+type switchConf struct {
+	Desc  string `json:"desc"`
+	Cases []struct {
+		When  Condition         `json:"when"`
+		Pipes []json.RawMessage `json:"pipes"`
+	} `json:"cases"`
+	Default []json.RawMessage `json:"default"` // nil means no default
+}
+
+// mapConf used to parse the conf of a map Pipe.
+type mapConf struct {
+	Desc           string          `json:"desc"`
+	MaxConcurrency int             `json:"max_concurrency"`
+	Pipe           json.RawMessage `json:"pipe"`
+}
+
+// confParser parses the JSON conf of a Line recursively,
+// every error returned is prefixed with the JSON path of the bad node, e.g. $[1].pipes[0].
+// With a non-nil errs, the parser collects all the errors into it and goes on parsing the other nodes,
+// the returned Line is incomplete then.
+type confParser struct {
+	handlerBuilders HandlerBuilderGetter
+	handlers        HandlerGetter
+	opts            *options
+	errs            *joinedErrs
+}
+
+// report returns the err to stop parsing, or collects it and returns nil if the p.errs is not nil.
+func (p confParser) report(err error) error {
+	if p.errs == nil {
+		return err
+	}
+	*p.errs = append(*p.errs, err)
+	return nil
+}
+
+// parseLine parses the raws into a Line, an array item is a shorthand of a parallel Pipe.
+func (p confParser) parseLine(path string, raws []json.RawMessage) (*Line, error) {
+	line := &Line{Pipes: make([]Pipe, 0, len(raws)), Observer: newObserver(p.opts.observers)}
+	for i, raw := range raws {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		var (
+			pipe *Pipe
+			err  error
+		)
+		if isJSONArray(raw) {
+			var items []json.RawMessage
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, fmt.Errorf("%s: %w", itemPath, err)
+			}
+			if pipe, err = p.parseParallelPipe(itemPath, itemPath, parallelConf{Pipes: items}); err == nil {
+				pipe.Path = itemPath
+				pipe.Observer = newObserver(p.opts.observers)
+			}
+		} else {
+			pipe, err = p.parseNode(itemPath, raw)
+		}
+		if err != nil {
+			if err := p.report(err); err != nil {
+				return nil, err
+			}
+			pipe = &Pipe{Path: itemPath}
+		}
+		line.Pipes = append(line.Pipes, *pipe)
+	}
+	return line, nil
+}
+
+// parseParallel parses the raws into a Parallel, every item must be a JSON object.
+func (p confParser) parseParallel(path string, raws []json.RawMessage) (*Parallel, error) {
+	parallel := &Parallel{Pipes: make([]Pipe, 0, len(raws))}
+	for i, raw := range raws {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		var (
+			pipe *Pipe
+			err  error
+		)
+		if isJSONArray(raw) {
+			err = fmt.Errorf("%s: %w", itemPath, ErrPipeConfNestedArray)
+		} else {
+			pipe, err = p.parseNode(itemPath, raw)
+		}
+		if err != nil {
+			if err := p.report(err); err != nil {
+				return nil, err
+			}
+			pipe = &Pipe{Path: itemPath}
+		}
+		parallel.Pipes = append(parallel.Pipes, *pipe)
+	}
+	return parallel, nil
+}
+
+// parseNode parses a JSON object into a Pipe by its "type", a single Pipe by default.
+func (p confParser) parseNode(path string, raw json.RawMessage) (*Pipe, error) {
+	pipe, err := p.parseTypedNode(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	pipe.Path = path
+	pipe.Observer = newObserver(p.opts.observers)
+	return pipe, nil
+}
+
+func (p confParser) parseTypedNode(path string, raw json.RawMessage) (*Pipe, error) {
+	if !isJSONObject(raw) {
+		return nil, fmt.Errorf("%s: %w", path, ErrPipeConfNotObject)
+	}
+
+	var probe struct {
+		Type PipeType `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch probe.Type {
+	case "", PipeTypeSingle:
+		var pc PipeConf
+		if err := json.Unmarshal(raw, &pc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return p.parseSinglePipe(path, pc)
+	case PipeTypeLine:
+		var gc groupConf
+		if err := json.Unmarshal(raw, &gc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, err := p.parseLine(path+".pipes", gc.Pipes)
+		if err != nil {
+			return nil, err
+		}
+		return &Pipe{
+			Type:    PipeTypeLine,
+			Conf:    PipeConf{Desc: gc.Desc},
+			Handler: line,
+		}, nil
+	case PipeTypeParallel:
+		var pc parallelConf
+		if err := json.Unmarshal(raw, &pc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return p.parseParallelPipe(path, path+".pipes", pc)
+	case PipeTypeSwitch:
+		var sc switchConf
+		if err := json.Unmarshal(raw, &sc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return p.parseSwitchPipe(path, sc)
+	case PipeTypeMap:
+		var mc mapConf
+		if err := json.Unmarshal(raw, &mc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if mc.MaxConcurrency < 0 {
+			if err := p.report(fmt.Errorf("%s: %w", path, ErrPipeConfNegativeMaxConcurrency)); err != nil {
+				return nil, err
+			}
+		}
+		pipe, err := p.parseNode(path+".pipe", mc.Pipe)
+		if err != nil {
+			return nil, err
+		}
+		return &Pipe{
+			Type:    PipeTypeMap,
+			Conf:    PipeConf{Desc: mc.Desc},
+			Handler: &Map{Pipe: *pipe, MaxConcurrency: mc.MaxConcurrency},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s: %w: %s", path, ErrPipeConfUnknownType, probe.Type)
+	}
+}
+
+// parseSinglePipe creates a single Pipe with the pc like NewSinglePipe, the path is the path of the pc.
+func (p confParser) parseSinglePipe(path string, pc PipeConf) (*Pipe, error) {
+	prefix := path
+	if pc.Desc != "" {
+		prefix += ": " + pc.Desc
+	}
+	for _, err := range pc.validateAll(p.opts.retryableErrs) {
+		if err := p.report(fmt.Errorf("%s: %w", prefix, err)); err != nil {
+			return nil, err
+		}
+	}
+
+	handler, err := newSingleHandler(pc, p.handlerBuilders, p.handlers)
+	if err != nil {
+		if err := p.report(fmt.Errorf("%s: %w", path, err)); err != nil {
+			return nil, err
+		}
+	}
+	if handler, err = p.wrapMiddlewares(handler, pc.Middlewares); err != nil {
+		if err := p.report(fmt.Errorf("%s: %w", path, err)); err != nil {
+			return nil, err
+		}
+	}
+
+	if pc.Retry != nil {
+		pc.Retry = pc.Retry.resolved(p.opts.retryableErrs)
+	}
+	return &Pipe{
+		Type:    PipeTypeSingle,
+		Conf:    pc,
+		Handler: handler,
+		Limiter: p.opts.limiter,
+
+		RecordMeta: p.opts.recordMeta,
+	}, nil
+}
+
+// parseParallelPipe creates a parallel Pipe with the pc, the path is the path of the pc,
+// the itemsPath is the path of the pc.Pipes.
+func (p confParser) parseParallelPipe(path string, itemsPath string, pc parallelConf) (*Pipe, error) {
+	parallel, err := p.parseParallel(itemsPath, pc.Pipes)
+	if err != nil {
+		return nil, err
+	}
+
+	if pc.Merge != "" {
+		merger, ok := p.getMerger(pc.Merge)
+		if !ok {
+			if err := p.report(fmt.Errorf("%s: %w: %s", path, ErrMergerNotFound, pc.Merge)); err != nil {
+				return nil, err
+			}
+		}
+		parallel.Merger = merger
+	}
+	if err := pc.MetaMerge.Validate(); err != nil {
+		if err := p.report(fmt.Errorf("%s: %w", path, err)); err != nil {
+			return nil, err
+		}
+	}
+	parallel.MetaMerge = pc.MetaMerge
+	parallel.Mode = pc.Mode
+	parallel.Quorum = pc.Quorum
+	parallel.MaxConcurrency = pc.MaxConcurrency
+	parallel.Observer = newObserver(p.opts.observers)
+	if err := parallel.Validate(); err != nil {
+		if err := p.report(fmt.Errorf("%s: %w", path, err)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Pipe{
+		Type:    PipeTypeParallel,
+		Conf:    PipeConf{Desc: pc.Desc},
+		Handler: parallel,
+	}, nil
+}
+
+// getMerger finds the Merger with the name in the built-in ones, then the ones given by WithMergers.
+func (p confParser) getMerger(name string) (Merger, bool) {
+	if merger, ok := builtinMergers.GetMergerOK(name); ok {
+		return merger, true
+	}
+	if p.opts.mergers == nil {
+		return nil, false
+	}
+	return p.opts.mergers.GetMergerOK(name)
+}
+
+// parseSwitchPipe creates a switch Pipe with the sc, the path is the path of the sc.
+func (p confParser) parseSwitchPipe(path string, sc switchConf) (*Pipe, error) {
+	s := &Switch{Cases: make([]SwitchCase, 0, len(sc.Cases))}
+	for i, c := range sc.Cases {
+		casePath := fmt.Sprintf("%s.cases[%d]", path, i)
+		if err := c.When.compile(casePath + ".when"); err != nil {
+			if err := p.report(err); err != nil {
+				return nil, err
+			}
+		}
+		line, err := p.parseLine(casePath+".pipes", c.Pipes)
+		if err != nil {
+			return nil, err
+		}
+		s.Cases = append(s.Cases, SwitchCase{When: c.When, Line: line})
+	}
+
+	if sc.Default != nil {
+		line, err := p.parseLine(path+".default", sc.Default)
+		if err != nil {
+			return nil, err
+		}
+		s.Default = line
+	}
+
+	return &Pipe{
+		Type:    PipeTypeSwitch,
+		Conf:    PipeConf{Desc: sc.Desc},
+		Handler: s,
+	}, nil
+}
+
+// wrapMiddlewares wraps the handler with the global Middlewares and the named ones in order,
+// the first one is the outermost.
+func (p confParser) wrapMiddlewares(handler Handler, names []string) (Handler, error) {
+	mws := make([]Middleware, 0, len(p.opts.middlewares)+len(names))
+	mws = append(mws, p.opts.middlewares...)
+	for _, name := range names {
+		var (
+			mw Middleware
+			ok bool
+		)
+		if p.opts.namedMiddlewares != nil {
+			mw, ok = p.opts.namedMiddlewares.GetMiddlewareOK(name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", name, ErrMiddlewareNotFound)
+		}
+		mws = append(mws, mw)
+	}
+	return Chain(mws...)(handler), nil
+}
+
+func isJSONArray(raw json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), []byte("["))
+}
+
+func isJSONObject(raw json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{"))
+}
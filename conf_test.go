@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testNestedJSONConf = `
+[
+    {
+        "ref_handler_id":"by_square",
+        "timeout":20,
+        "required":true
+    },
+    {
+        "type":"parallel",
+        "desc":"square-and-cubic",
+        "pipes":[
+            {
+                "type":"line",
+                "desc":"square-twice",
+                "pipes":[
+                    {
+                        "ref_handler_id":"by_square",
+                        "timeout":20,
+                        "required":true
+                    },
+                    {
+                        "ref_handler_id":"by_square",
+                        "timeout":20,
+                        "required":true
+                    }
+                ]
+            },
+            {
+                "type":"single",
+                "ref_handler_id":"by_cubic",
+                "timeout":20,
+                "required":true
+            },
+            {
+                "type":"line",
+                "pipes":[
+                    [
+                        {
+                            "ref_handler_id":"by_square",
+                            "timeout":20,
+                            "required":true
+                        },
+                        {
+                            "type":"parallel",
+                            "pipes":[
+                                {
+                                    "ref_handler_id":"by_cubic",
+                                    "timeout":20,
+                                    "required":true
+                                }
+                            ]
+                        }
+                    ]
+                ]
+            }
+        ]
+    }
+]
+`
+
+func TestNewLineByJSON_Nested(t *testing.T) {
+	line, err := NewLineByJSON(testNestedJSONConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line.Pipes[1].Type != PipeTypeParallel {
+		t.Errorf("type: want=%v, got=%v", PipeTypeParallel, line.Pipes[1].Type)
+	}
+	if line.Pipes[1].Conf.Desc != "square-and-cubic" {
+		t.Errorf("desc: want=%v, got=%v", "square-and-cubic", line.Pipes[1].Conf.Desc)
+	}
+	parallel := line.Pipes[1].Handler.(*Parallel)
+	if parallel.Pipes[0].Type != PipeTypeLine {
+		t.Errorf("type: want=%v, got=%v", PipeTypeLine, parallel.Pipes[0].Type)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HandleRes{
+		Status: HandleStatusOK,
+		Data:   []interface{}{256, 64, []interface{}{16, []interface{}{64}}},
+	}
+	if text, ok := diff(want, res); !ok {
+		t.Error("res diff:\n", text)
+	}
+}
+
+func TestNewLineByJSON_ErrPath(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		path     string
+		err      error
+		errMsg   string
+	}{
+		{
+			caseName: "not a object",
+			jsonConf: `[1]`,
+			path:     "$[0]",
+			err:      ErrPipeConfNotObject,
+		},
+		{
+			caseName: "unknown type",
+			jsonConf: `[{"type":"loop"}]`,
+			path:     "$[0]",
+			err:      ErrPipeConfUnknownType,
+		},
+		{
+			caseName: "array in parallel",
+			jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true},[[]]]`,
+			path:     "$[1][0]",
+			err:      ErrPipeConfNestedArray,
+		},
+		{
+			caseName: "bad node in nested line",
+			jsonConf: `[{"type":"parallel","pipes":[{"type":"line","pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true},{"ref_handler_id":"not_found","timeout":20,"required":true}]}]}]`,
+			path:     "$[0].pipes[0].pipes[1]",
+			err:      ErrRefHandlerNotFound,
+		},
+		{
+			caseName: "bad node in shorthand parallel",
+			jsonConf: `[[{"ref_handler_id":"by_square","timeout":0,"required":true}]]`,
+			path:     "$[0][0]",
+			err:      ErrPipeConfTimeoutLessThanOrEqualToZero,
+			errMsg:   "$[0][0]: timeout less than or equal to 0",
+		},
+		{
+			caseName: "bad node with desc",
+			jsonConf: `[{"desc":"square","ref_handler_id":"by_square","timeout":0,"required":true}]`,
+			path:     "$[0]",
+			err:      ErrPipeConfTimeoutLessThanOrEqualToZero,
+			errMsg:   "$[0]: square: timeout less than or equal to 0",
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			_, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if !errors.Is(err, item.err) {
+				t.Fatalf("err: want=%v, got=%v", item.err, err)
+			}
+			if !strings.HasPrefix(err.Error(), item.path+":") {
+				t.Errorf("err path: want=%v, got=%v", item.path, err)
+			}
+			if item.errMsg != "" && err.Error() != item.errMsg {
+				t.Errorf("err msg: want=%q, got=%q", item.errMsg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"reflect"
+)
+
+// DeepCopier is implemented by the types copying themselves, DeepCopy uses it instead of reflection,
+// the returned value must be of the same type as the receiver.
+type DeepCopier interface {
+	DeepCopy() interface{}
+}
+
+// DeepCopy returns a deep copy of the v, the types of all the values are preserved,
+// e.g. an int stays an int, a time.Duration stays a time.Duration.
+// Pointers, maps and slices referenced more than once are copied once, so cycles are kept as cycles.
+// Channels, funcs and the unexported fields of structs are shared with the v.
+func DeepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	c := copier{seen: make(map[copyKey]reflect.Value)}
+	return c.copy(reflect.ValueOf(v)).Interface()
+}
+
+var deepCopierType = reflect.TypeOf((*DeepCopier)(nil)).Elem()
+
+// copyKey identifies a pointer, a map or a slice already copied.
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+type copier struct {
+	seen map[copyKey]reflect.Value
+}
+
+func (c copier) copy(src reflect.Value) reflect.Value {
+	if dst, ok := c.copyByDeepCopier(src); ok {
+		return dst
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return src
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type()}
+		if dst, ok := c.seen[key]; ok {
+			return dst
+		}
+		dst := reflect.New(src.Type().Elem())
+		c.seen[key] = dst
+		dst.Elem().Set(c.copy(src.Elem()))
+		return dst
+	case reflect.Interface:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(c.copy(src.Elem()))
+		return dst
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type()}
+		if dst, ok := c.seen[key]; ok {
+			return dst
+		}
+		dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.seen[key] = dst
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(c.copy(iter.Key()), c.copy(iter.Value()))
+		}
+		return dst
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type(), len: src.Len()}
+		if dst, ok := c.seen[key]; ok && src.Len() > 0 {
+			return dst
+		}
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		c.seen[key] = dst
+		for i := 0; i < src.Len(); i++ {
+			dst.Index(i).Set(c.copy(src.Index(i)))
+		}
+		return dst
+	case reflect.Array:
+		dst := reflect.New(src.Type()).Elem()
+		for i := 0; i < src.Len(); i++ {
+			dst.Index(i).Set(c.copy(src.Index(i)))
+		}
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if field := dst.Field(i); field.CanSet() {
+				field.Set(c.copy(src.Field(i)))
+			}
+		}
+		return dst
+	default:
+		return src
+	}
+}
+
+// copyByDeepCopier copies the src by its DeepCopy method if it implements DeepCopier.
+func (c copier) copyByDeepCopier(src reflect.Value) (reflect.Value, bool) {
+	if !src.IsValid() || !src.Type().Implements(deepCopierType) || !src.CanInterface() {
+		return reflect.Value{}, false
+	}
+	if (src.Kind() == reflect.Ptr || src.Kind() == reflect.Interface) && src.IsNil() {
+		return reflect.Value{}, false
+	}
+	dst := reflect.ValueOf(src.Interface().(DeepCopier).DeepCopy())
+	if !dst.IsValid() || !dst.Type().AssignableTo(src.Type()) {
+		return reflect.Value{}, false
+	}
+	return dst, true
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	Name string
+	Next *testNode
+}
+
+type testCopier struct {
+	Calls *int
+}
+
+func (c testCopier) DeepCopy() interface{} {
+	*c.Calls++
+	return testCopier{Calls: c.Calls}
+}
+
+func TestDeepCopy(t *testing.T) {
+	ch := make(chan int)
+	tt := []struct {
+		caseName string
+		src      interface{}
+	}{
+		{caseName: "nil", src: nil},
+		{caseName: "int", src: 1},
+		{caseName: "duration", src: time.Second},
+		{caseName: "time", src: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)},
+		{caseName: "map", src: map[string]interface{}{"int": 1, "list": []interface{}{int64(2), "3"}, "nil": nil}},
+		{caseName: "struct", src: testUser{Name: "foo", Age: 18, Tags: []string{"a"}}},
+		{caseName: "pointer of struct", src: &testUser{Name: "foo"}},
+		{caseName: "array", src: [2]map[string]int{{"a": 1}, {"b": 2}}},
+		{caseName: "channel", src: ch},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			got := DeepCopy(item.src)
+			if !reflect.DeepEqual(item.src, got) {
+				t.Errorf("want=%#v, got=%#v", item.src, got)
+			}
+		})
+	}
+
+	t.Run("not shared", func(t *testing.T) {
+		src := map[string]interface{}{"list": []interface{}{map[string]interface{}{"k": "v"}}}
+		got := DeepCopy(src).(map[string]interface{})
+		got["list"].([]interface{})[0].(map[string]interface{})["k"] = "changed"
+		if src["list"].([]interface{})[0].(map[string]interface{})["k"] != "v" {
+			t.Error("src changed by the copy")
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		src := &testNode{Name: "a"}
+		src.Next = &testNode{Name: "b", Next: src}
+		got := DeepCopy(src).(*testNode)
+		if got == src || got.Next == src.Next {
+			t.Error("pointers not copied")
+		}
+		if got.Next.Next != got {
+			t.Error("cycle not kept")
+		}
+		if got.Name != "a" || got.Next.Name != "b" {
+			t.Errorf("names: got=%s, %s", got.Name, got.Next.Name)
+		}
+	})
+
+	t.Run("shared pointer", func(t *testing.T) {
+		user := &testUser{Name: "foo"}
+		got := DeepCopy([]*testUser{user, user}).([]*testUser)
+		if got[0] != got[1] || got[0] == user {
+			t.Error("shared pointer not copied once")
+		}
+	})
+
+	t.Run("DeepCopier", func(t *testing.T) {
+		calls := 0
+		got := DeepCopy(map[string]interface{}{"c": testCopier{Calls: &calls}})
+		if calls != 1 {
+			t.Errorf("calls: want=1, got=%d", calls)
+		}
+		if _, ok := got.(map[string]interface{})["c"].(testCopier); !ok {
+			t.Errorf("type: got=%T", got.(map[string]interface{})["c"])
+		}
+	})
+}
+
+func TestHandleRes_Copy_Types(t *testing.T) {
+	res := &HandleRes{
+		Status: HandleStatusOK,
+		Meta:   map[string]interface{}{"timeout": time.Second},
+		Data:   []int{1, 2},
+	}
+	resCopy, err := res.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, resCopy) {
+		t.Errorf("want=%#v, got=%#v", res, resCopy)
+	}
+	resCopy.Data.([]int)[0] = 3
+	if res.Data.([]int)[0] != 1 {
+		t.Error("res changed by the copy")
+	}
+
+	var nilRes *HandleRes
+	if resCopy, err := nilRes.Copy(); err != nil || resCopy == nil {
+		t.Errorf("nil res: got=%v, %v", resCopy, err)
+	}
+}
+
+// jsonCopy is the former implementation of HandleRes.Copy, kept for the benchmarks.
+func jsonCopy(res *HandleRes) (*HandleRes, error) {
+	var resCopy HandleRes
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &resCopy); err != nil {
+		return nil, err
+	}
+	return &resCopy, nil
+}
+
+func benchmarkRes() *HandleRes {
+	items := make([]interface{}, 0, 20)
+	for i := 0; i < 20; i++ {
+		items = append(items, map[string]interface{}{"id": i, "name": "foo", "tags": []interface{}{"a", "b"}})
+	}
+	return &HandleRes{
+		Status:  HandleStatusOK,
+		Message: "OK",
+		Meta:    map[string]interface{}{"request_id": "foo"},
+		Data:    map[string]interface{}{"items": items, "total": 20},
+	}
+}
+
+func BenchmarkHandleRes_Copy(b *testing.B) {
+	res := benchmarkRes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := res.Copy(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHandleRes_JSONCopy(b *testing.B) {
+	res := benchmarkRes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := jsonCopy(res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
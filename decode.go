@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	millisType   = reflect.TypeOf(Millis(0))
+)
+
+// NewTypedHandlerBuilder creates a HandlerBuilder which decodes the conf into a C by DecodeConf,
+// then builds the Handler with it by the build.
+func NewTypedHandlerBuilder[C any](build func(conf C) (Handler, error)) HandlerBuilder {
+	return HandlerBuilderFunc(func(conf map[string]interface{}) (Handler, error) {
+		var c C
+		if err := DecodeConf(conf, &c); err != nil {
+			return nil, err
+		}
+		return build(c)
+	})
+}
+
+// DecodeConf decodes the conf of a HandlerBuilder into the struct pointed by the out.
+// The fields are keyed by the name in their "json" tag, or the field name without the tag,
+// a field tagged with `default:"<value>"` uses the value when its key is missing or null,
+// the value is parsed as JSON, or used as a string if it is not valid JSON,
+// a field tagged with `required:"true"` must not be missing or null.
+// A value is set directly if it is assignable to the field, e.g. a func or an error,
+// a time.Duration or Millis field accepts the values of ToDuration,
+// other fields are converted from the values decoded from JSON recursively.
+// All the bad fields are reported with their paths, e.g. "headers.accept", in one error wrapping ErrBuildHandlerFailed.
+func DecodeConf(conf map[string]interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: decode conf into %T, want a pointer to struct", ErrBuildHandlerFailed, out)
+	}
+
+	decoder := &confDecoder{errRequired: ErrBuilderConfFieldRequired, errInvalid: ErrBuilderConfFieldInvalid}
+	decoder.decodeStruct("", conf, rv.Elem())
+	if len(decoder.errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrBuildHandlerFailed, decoder.errs)
+	}
+	return nil
+}
+
+// joinedErrs joins the errors with "; ".
+type joinedErrs []error
+
+func (errs joinedErrs) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (errs joinedErrs) Unwrap() []error {
+	return errs
+}
+
+// confDecoder collects the errors of all the fields while decoding,
+// the errRequired and errInvalid are wrapped by the errors of the missing and the invalid fields.
+type confDecoder struct {
+	errRequired error
+	errInvalid  error
+	errs        joinedErrs
+}
+
+func (d *confDecoder) fail(path string, err error, format string, args ...interface{}) {
+	d.errs = append(d.errs, fmt.Errorf("%s: %w: "+format, append([]interface{}{path, err}, args...)...))
+}
+
+func (d *confDecoder) decodeStruct(path string, conf map[string]interface{}, dst reflect.Value) {
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			// the fields of an embedded struct are promoted like encoding/json
+			d.decodeStruct(path, conf, dst.Field(i))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag, _, _ = strings.Cut(tag, ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		v := conf[name]
+		if v == nil {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				v = parseDefault(def, field.Type)
+			} else if field.Tag.Get("required") == "true" {
+				d.fail(fieldPath, d.errRequired, "missing")
+				continue
+			}
+		}
+		d.decode(fieldPath, v, dst.Field(i))
+	}
+}
+
+// parseDefault parses the def as JSON, uses it as a string for a string field or when it is not valid JSON.
+func parseDefault(def string, typ reflect.Type) interface{} {
+	if typ.Kind() == reflect.String {
+		return def
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(def), &v); err != nil {
+		return def
+	}
+	return v
+}
+
+func (d *confDecoder) decode(path string, v interface{}, dst reflect.Value) {
+	if v == nil {
+		return
+	}
+
+	typ := dst.Type()
+	if typ == durationType || typ == millisType {
+		duration, err := ToDuration(v)
+		if err != nil {
+			d.fail(path, d.errInvalid, "%w", err)
+			return
+		}
+		if typ == millisType {
+			dst.Set(reflect.ValueOf(Millis(duration / time.Millisecond)))
+		} else {
+			dst.Set(reflect.ValueOf(duration))
+		}
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(typ) {
+		dst.Set(rv)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(typ.Elem())
+		n := len(d.errs)
+		if d.decode(path, v, elem.Elem()); len(d.errs) == n {
+			dst.Set(elem)
+		}
+		return
+	case reflect.Bool, reflect.String:
+		if rv.Kind() == typ.Kind() {
+			dst.Set(rv.Convert(typ))
+			return
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f, ok := toFloat64(v); ok && f == math.Trunc(f) && !dst.OverflowInt(int64(f)) {
+			dst.SetInt(int64(f))
+			return
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f, ok := toFloat64(v); ok && f >= 0 && f == math.Trunc(f) && !dst.OverflowUint(uint64(f)) {
+			dst.SetUint(uint64(f))
+			return
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat64(v); ok && !dst.OverflowFloat(f) {
+			dst.SetFloat(f)
+			return
+		}
+	case reflect.Slice:
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			slice := reflect.MakeSlice(typ, rv.Len(), rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				d.decode(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), slice.Index(i))
+			}
+			dst.Set(slice)
+			return
+		}
+	case reflect.Map:
+		if typ.Key().Kind() == reflect.String && rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			m := reflect.MakeMapWithSize(typ, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				key := iter.Key().String()
+				elem := reflect.New(typ.Elem()).Elem()
+				d.decode(path+"."+key, iter.Value().Interface(), elem)
+				m.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), elem)
+			}
+			dst.Set(m)
+			return
+		}
+	case reflect.Struct:
+		if conf, ok := v.(map[string]interface{}); ok {
+			d.decodeStruct(path, conf, dst)
+			return
+		}
+	}
+	d.fail(path, d.errInvalid, "want %s, got %T", typ, v)
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRetryBuilderConf struct {
+	Times int `json:"times" default:"3"`
+}
+
+type testBuilderConf struct {
+	testRetryBuilderConf
+	URL      string            `json:"url" required:"true"`
+	Method   string            `json:"method" default:"GET"`
+	Timeout  time.Duration     `json:"timeout" default:"1.5s"`
+	Interval Millis            `json:"interval"`
+	Ratio    float64           `json:"ratio"`
+	Port     uint16            `json:"port"`
+	Verbose  *bool             `json:"verbose"`
+	Tags     []string          `json:"tags"`
+	Headers  map[string]string `json:"headers"`
+	Auth     struct {
+		User     string `json:"user" required:"true"`
+		Password string `json:"password"`
+	} `json:"auth"`
+	Extra  interface{} `json:"extra"`
+	Err    error       `json:"err"`
+	Ignore string      `json:"-"`
+	hidden string
+}
+
+func TestDecodeConf(t *testing.T) {
+	var jsonConf map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"url": "http://localhost",
+		"interval": "250ms",
+		"ratio": 0.5,
+		"port": 8080,
+		"verbose": true,
+		"tags": ["a", "b"],
+		"headers": {"accept": "application/json"},
+		"auth": {"user": "root"},
+		"extra": {"k": [1]},
+		"-": "ignored"
+	}`), &jsonConf); err != nil {
+		t.Fatal(err)
+	}
+	jsonConf["err"] = errUnknown
+
+	var conf testBuilderConf
+	if err := DecodeConf(jsonConf, &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	verbose := true
+	want := testBuilderConf{
+		testRetryBuilderConf: testRetryBuilderConf{Times: 3},
+		URL:                  "http://localhost",
+		Method:               "GET",
+		Timeout:              time.Millisecond * 1500,
+		Interval:             250,
+		Ratio:                0.5,
+		Port:                 8080,
+		Verbose:              &verbose,
+		Tags:                 []string{"a", "b"},
+		Headers:              map[string]string{"accept": "application/json"},
+		Extra:                map[string]interface{}{"k": []interface{}{float64(1)}},
+		Err:                  errUnknown,
+	}
+	want.Auth.User = "root"
+	if text, ok := diff(want, conf); !ok {
+		t.Error("diff:\n", text)
+	}
+}
+
+func TestDecodeConf_Errors(t *testing.T) {
+	var conf testBuilderConf
+	err := DecodeConf(map[string]interface{}{
+		"times":   1.5,
+		"timeout": "soon",
+		"port":    -1,
+		"tags":    []interface{}{"a", 1},
+		"headers": map[string]interface{}{"accept": true},
+		"auth":    map[string]interface{}{},
+		"err":     "not an error",
+	}, &conf)
+
+	if !errors.Is(err, ErrBuildHandlerFailed) {
+		t.Fatalf("err: want=%v, got=%v", ErrBuildHandlerFailed, err)
+	}
+	if !errors.Is(err, ErrBuilderConfFieldRequired) || !errors.Is(err, ErrBuilderConfFieldInvalid) || !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("err: want all the field errors, got=%v", err)
+	}
+	for _, path := range []string{"times: ", "url: ", "timeout: ", "port: ", "tags[1]: ", "headers.accept: ", "auth.user: ", "err: "} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("err: want %q, got=%v", path, err)
+		}
+	}
+
+	if err := DecodeConf(nil, conf); !errors.Is(err, ErrBuildHandlerFailed) {
+		t.Errorf("non-pointer err: want=%v, got=%v", ErrBuildHandlerFailed, err)
+	}
+}
+
+func TestNewTypedHandlerBuilder(t *testing.T) {
+	_, err := NewSinglePipe(PipeConf{
+		Timeout:            20,
+		Required:           true,
+		HandlerBuilderName: "delay",
+		HandlerBuilderConf: map[string]interface{}{"delay": "soon"},
+	}, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if !errors.Is(err, ErrBuildHandlerFailed) || !errors.Is(err, ErrInvalidDuration) {
+		t.Fatalf("err: want=%v, got=%v", ErrInvalidDuration, err)
+	}
+	if n := strings.Count(err.Error(), ErrBuildHandlerFailed.Error()); n != 1 {
+		t.Errorf("err: want wrapped once, got=%v", err)
+	}
+
+	_, err = NewSinglePipe(PipeConf{
+		Timeout:            20,
+		Required:           true,
+		HandlerBuilderName: "failed",
+	}, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if !errors.Is(err, ErrBuilderConfFieldRequired) {
+		t.Errorf("err: want=%v, got=%v", ErrBuilderConfFieldRequired, err)
+	}
+}
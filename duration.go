@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Millis is a duration in millisecond, it can be decoded from a JSON number of milliseconds,
+// or a JSON string parsed by time.ParseDuration, e.g. "1.5s" or "250ms", which is truncated to milliseconds.
+// It is encoded as a JSON number.
+type Millis int
+
+// Duration returns the time.Duration of the ms.
+func (ms Millis) Duration() time.Duration {
+	return time.Millisecond * time.Duration(ms)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler.
+func (ms *Millis) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	d, err := ToDuration(v)
+	if err != nil {
+		return err
+	}
+	*ms = Millis(d / time.Millisecond)
+	return nil
+}
+
+// ToDuration converts the v into a time.Duration, the v can be:
+//  1. a time.Duration or a Millis;
+//  2. a number of milliseconds, e.g. a float64 decoded from JSON;
+//  3. a string parsed by time.ParseDuration, e.g. "1.5s" or "250ms".
+func ToDuration(v interface{}) (time.Duration, error) {
+	switch d := v.(type) {
+	case time.Duration:
+		return d, nil
+	case Millis:
+		return d.Duration(), nil
+	case string:
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, d)
+		}
+		return duration, nil
+	case json.Number:
+		if n, err := d.Int64(); err == nil {
+			return time.Millisecond * time.Duration(n), nil
+		}
+		if f, err := d.Float64(); err == nil {
+			return time.Duration(f * float64(time.Millisecond)), nil
+		}
+	case float64:
+		return time.Duration(d * float64(time.Millisecond)), nil
+	case float32:
+		return time.Duration(float64(d) * float64(time.Millisecond)), nil
+	case int:
+		return time.Millisecond * time.Duration(d), nil
+	case int64:
+		return time.Millisecond * time.Duration(d), nil
+	case int32:
+		return time.Millisecond * time.Duration(d), nil
+	}
+	return 0, fmt.Errorf("%w: %v", ErrInvalidDuration, v)
+}
+
+// ConfDuration gets the duration of the key from the conf of a HandlerBuilder, see ToDuration.
+// Returns ErrInvalidDuration when the key is missing or the value is invalid.
+func ConfDuration(conf map[string]interface{}, key string) (time.Duration, error) {
+	v, ok := conf[key]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w: missing", key, ErrInvalidDuration)
+	}
+	d, err := ToDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
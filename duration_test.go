@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMillis_UnmarshalJSON(t *testing.T) {
+	tt := []struct {
+		caseName string
+		json     string
+		ms       Millis
+		err      bool
+	}{
+		{caseName: "number", json: `20`, ms: 20},
+		{caseName: "float number", json: `20.9`, ms: 20},
+		{caseName: "null", json: `null`, ms: 0},
+		{caseName: "seconds", json: `"1.5s"`, ms: 1500},
+		{caseName: "milliseconds", json: `"250ms"`, ms: 250},
+		{caseName: "truncated", json: `"1500us"`, ms: 1},
+		{caseName: "no unit", json: `"20"`, err: true},
+		{caseName: "bool", json: `true`, err: true},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			var ms Millis
+			err := json.Unmarshal([]byte(item.json), &ms)
+			if item.err {
+				if !errors.Is(err, ErrInvalidDuration) {
+					t.Errorf("err: want=%v, got=%v", ErrInvalidDuration, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ms != item.ms {
+				t.Errorf("want=%v, got=%v", item.ms, ms)
+			}
+		})
+	}
+}
+
+func TestMillis_RoundTrip(t *testing.T) {
+	var pc PipeConf
+	if err := json.Unmarshal([]byte(`{"timeout":"1.5s","retry":{"max_attempts":2,"interval":"100ms","attempt_timeout":500}}`), &pc); err != nil {
+		t.Fatal(err)
+	}
+	if pc.Timeout != 1500 || pc.Retry.Interval != 100 || pc.Retry.AttemptTimeout != 500 {
+		t.Errorf("got: timeout=%v, interval=%v, attempt_timeout=%v", pc.Timeout, pc.Retry.Interval, pc.Retry.AttemptTimeout)
+	}
+	if pc.Retry.Interval.Duration() != time.Millisecond*100 {
+		t.Errorf("duration: want=%v, got=%v", time.Millisecond*100, pc.Retry.Interval.Duration())
+	}
+
+	b, err := json.Marshal(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copied PipeConf
+	if err := json.Unmarshal(b, &copied); err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(pc, copied); !ok {
+		t.Error("diff:\n", text)
+	}
+}
+
+func TestPipeConf_UnmarshalJSON(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		pc       PipeConf
+		err      error
+	}{
+		{
+			caseName: "number",
+			jsonConf: `{"desc":"square","timeout":20,"required":true,"ref_handler_id":"by_square"}`,
+			pc:       PipeConf{Desc: "square", Timeout: 20, Required: true, RefHandlerID: "by_square"},
+		},
+		{
+			caseName: "duration string",
+			jsonConf: `{"desc":"square","timeout":"1s","default_data":0}`,
+			pc:       PipeConf{Desc: "square", Timeout: 1000, DefaultData: 0},
+		},
+		{
+			caseName: "invalid duration",
+			jsonConf: `{"timeout":"abc"}`,
+			err:      ErrInvalidDuration,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			var pc PipeConf
+			err := json.Unmarshal([]byte(item.jsonConf), &pc)
+			if item.err != nil {
+				if !errors.Is(err, item.err) {
+					t.Errorf("err: want=%v, got=%v", item.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.pc, pc); !ok {
+				t.Error("diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestConfDuration(t *testing.T) {
+	conf := map[string]interface{}{
+		"duration": time.Second,
+		"string":   "1m",
+		"float":    float64(20),
+		"int":      20,
+		"millis":   Millis(20),
+		"invalid":  "abc",
+		"bool":     true,
+	}
+
+	tt := []struct {
+		key string
+		d   time.Duration
+		err bool
+	}{
+		{key: "duration", d: time.Second},
+		{key: "string", d: time.Minute},
+		{key: "float", d: time.Millisecond * 20},
+		{key: "int", d: time.Millisecond * 20},
+		{key: "millis", d: time.Millisecond * 20},
+		{key: "invalid", err: true},
+		{key: "bool", err: true},
+		{key: "missing", err: true},
+	}
+
+	for _, item := range tt {
+		t.Run(item.key, func(t *testing.T) {
+			d, err := ConfDuration(conf, item.key)
+			if item.err {
+				if !errors.Is(err, ErrInvalidDuration) {
+					t.Errorf("err: want=%v, got=%v", ErrInvalidDuration, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != item.d {
+				t.Errorf("want=%v, got=%v", item.d, d)
+			}
+		})
+	}
+}
+
+func TestNewLineByJSON_DurationStrings(t *testing.T) {
+	line, err := NewLineByJSON(`[
+		{"handler_builder_name": "delay", "handler_builder_conf": {"delay": "10ms"}, "timeout": "1s", "required": true},
+		{"handler_builder_name": "delay", "handler_builder_conf": {"delay": 1000}, "timeout": "20ms", "default_data": 0}
+	]`, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != HandleStatusTimeout {
+		t.Errorf("status: want=%v, got=%v", HandleStatusTimeout, res.Status)
+	}
+
+	_, err = NewLineByJSON(`[{"handler_builder_name": "delay", "handler_builder_conf": {"delay": "soon"}, "timeout": 20, "required": true}]`,
+		exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if !errors.Is(err, ErrBuildHandlerFailed) {
+		t.Errorf("err: want=%v, got=%v", ErrBuildHandlerFailed, err)
+	}
+}
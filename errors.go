@@ -3,18 +3,80 @@ package pipeline
 import (
 	"errors"
 	"fmt"
+	"runtime/debug"
 )
 
 var (
 	ErrBuildHandlerFailed                   = errors.New("build handler failed")
 	ErrRefHandlerNotFound                   = errors.New("ref handler not found")
+	ErrMiddlewareNotFound                   = errors.New("middleware not found")
 	ErrHandlerBuilderNotFound               = errors.New("handler builder not found")
 	ErrHandleFailed                         = errors.New("handle failed")
 	ErrHandleTimeout                        = errors.New("handle timeout")
+	ErrHandlerPanicked                      = errors.New("handler panicked")
 	ErrPipeConfTimeoutLessThanOrEqualToZero = errors.New("timeout less than or equal to 0")
 	ErrPipeConfNonRequiredNilDefaultData    = errors.New("non-required pipe need default data")
+	ErrPipeConfNotObject                    = errors.New("pipe conf is not a object")
+	ErrPipeConfNotArray                     = errors.New("pipe conf is not a array")
+	ErrPipeConfUnknownFormat                = errors.New("unknown pipe conf format")
+	ErrPipeConfNestedArray                  = errors.New("parallel pipe conf contains a array, use a line pipe instead")
+	ErrPipeConfUnknownType                  = errors.New("unknown pipe type")
+	ErrInvalidPath                          = errors.New("invalid path")
+	ErrDataPathNotFound                     = errors.New("data path not found")
+	ErrDataPathNotSettable                  = errors.New("data path not settable")
+	ErrConditionUnknownOp                   = errors.New("unknown condition op")
+	ErrConditionInvalidRegexp               = errors.New("invalid condition regexp")
+	ErrInvalidExpr                          = errors.New("invalid expression")
+	ErrExprEvalFailed                       = errors.New("expression evaluation failed")
+	ErrHTTPUnexpectedStatus                 = errors.New("unexpected http status")
+	ErrHTTPResponseTooLarge                 = errors.New("http response too large")
+	ErrExecOutputTooLarge                   = errors.New("exec output too large")
+	ErrSwitchNoCaseMatched                  = errors.New("switch no case matched")
+	ErrPipeConfNegativeMaxConcurrency       = errors.New("max concurrency less than 0")
+	ErrMapDataNotSlice                      = errors.New("map data is not a slice")
+	ErrPipeConfUnknownMetaMerge             = errors.New("unknown meta merge")
+	ErrPipeConfUnknownMetaPolicy            = errors.New("unknown meta policy")
+	ErrMergerNotFound                       = errors.New("merger not found")
+	ErrMergeFailed                          = errors.New("merge failed")
+	ErrMetaMergeConflict                    = errors.New("meta merge conflict")
+	ErrPipeConfUnknownParallelMode          = errors.New("unknown parallel mode")
+	ErrPipeConfInvalidQuorum                = errors.New("quorum out of range")
+	ErrParallelQuorumNotReached             = errors.New("parallel quorum not reached")
+	ErrBuilderConfFieldRequired             = errors.New("builder conf field required")
+	ErrBuilderConfFieldInvalid              = errors.New("invalid builder conf field")
+	ErrBuilderConfSchemaInvalid             = errors.New("invalid builder conf schema")
+	ErrBuilderConfSchemaMismatch            = errors.New("builder conf mismatches schema")
+	ErrDataTypeMismatch                     = errors.New("data type mismatch")
+	ErrInvalidDuration                      = errors.New("invalid duration")
+	ErrRetryConfMaxAttemptsLessThanOne      = errors.New("retry max attempts less than 1")
+	ErrRetryConfNegativeDuration            = errors.New("retry interval or timeout is negative")
+	ErrRetryConfUnknownBackoff              = errors.New("unknown retry backoff")
+	ErrRetryConfUnknownRetryableErr         = errors.New("unknown retryable err")
 )
 
 func MakeErrHandleTimeout(desc string, ms int) error {
 	return fmt.Errorf("%s: %w within %dms", desc, ErrHandleTimeout, ms)
 }
+
+// HandlerPanicError is made from a recovered panic of a Handler, it is a ErrHandlerPanicked.
+// The Stack is not in the Error, since the Error is used as the HandleRes.Message.
+type HandlerPanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack trace of the panicking goroutine
+}
+
+func (e *HandlerPanicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrHandlerPanicked, e.Value)
+}
+
+func (e *HandlerPanicError) Unwrap() error {
+	return ErrHandlerPanicked
+}
+
+// recoverHandlerPanic recovers the panic and assigns a *HandlerPanicError to the err,
+// it must be called by defer directly.
+func recoverHandlerPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = &HandlerPanicError{Value: v, Stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var panicking = HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+	panic("boom")
+})
+
+func TestPipe_Handle_Panic(t *testing.T) {
+	tt := []struct {
+		caseName string
+		pc       PipeConf
+		res      HandleRes
+		hasErr   bool
+	}{
+		{
+			caseName: "required",
+			pc:       PipeConf{Desc: "panicking", Timeout: 20, Required: true},
+			res:      HandleRes{Status: HandleStatusFailed},
+			hasErr:   true,
+		},
+		{
+			caseName: "non-required",
+			pc:       PipeConf{Desc: "panicking", Timeout: 20, DefaultData: -1},
+			res:      HandleRes{Status: HandleStatusFailed, Data: -1},
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			pipe := Pipe{Type: PipeTypeSingle, Conf: item.pc, Handler: panicking}
+			res, err := pipe.Handle(context.Background(), &HandleRes{})
+			if item.hasErr {
+				var panicErr *HandlerPanicError
+				if !errors.As(err, &panicErr) {
+					t.Fatalf("err: want=%T, got=%v", panicErr, err)
+				}
+				if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+					t.Errorf("panic err: want value=boom with stack, got=%v", panicErr)
+				}
+				if !errors.Is(err, ErrHandleFailed) || !errors.Is(err, ErrHandlerPanicked) {
+					t.Errorf("err: want=%v and %v, got=%v", ErrHandleFailed, ErrHandlerPanicked, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.HasSuffix(res.Message, "handler panicked: boom") {
+				t.Errorf("message: want ending with the panic value without the stack, got=%v", res.Message)
+			}
+			res.Message = ""
+			if text, ok := diff(item.res, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestParallel_Handle_Panic(t *testing.T) {
+	parallel := Parallel{Pipes: []Pipe{
+		{Type: PipeTypeSingle, Conf: PipeConf{Timeout: 20, Required: true}, Handler: bySquare},
+		{Type: PipeTypeLine, Handler: panicking},
+	}}
+
+	res, err := parallel.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if !errors.Is(err, ErrHandleFailed) {
+		t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+	}
+	if !strings.Contains(err.Error(), "2:handler panicked: boom") {
+		t.Errorf("err: want indexed panic err, got=%v", err)
+	}
+	if text, ok := diff(HandleRes{Status: HandleStatusFailed, Data: []interface{}{4, nil}}, res); !ok {
+		t.Error("res diff:\n", text)
+	}
+}
+
+func TestMap_Handle_Panic(t *testing.T) {
+	m := Map{Pipe: Pipe{Type: PipeTypeLine, Handler: panicking}}
+
+	_, err := m.Handle(context.Background(), &HandleRes{Data: []int{1}})
+	if !strings.Contains(err.Error(), "1:handler panicked: boom") {
+		t.Errorf("err: want indexed panic err, got=%v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// execWaitDelay limits the waiting for the output pipes after the process is killed.
+const execWaitDelay = time.Second
+
+// execErrOutputLimit limits the length of the stderr in the error of a failed process.
+const execErrOutputLimit = 512
+
+// ExecConf is the conf of the ExecHandlerBuilder.
+type ExecConf struct {
+	Command string   `json:"command" required:"true"` // name or path of the executable, looked up in PATH
+	Args    []string `json:"args"`
+	Dir     string   `json:"dir"` // empty means the working directory of the current process
+	Env     []string `json:"env"` // "KEY=value" items appended to the environment of the current process
+
+	MaxOutputBytes int64 `json:"max_output_bytes" default:"1048576"` // a larger stdout fails with ErrExecOutputTooLarge
+}
+
+const execConfSchema = `{
+	"type": "object",
+	"properties": {
+		"command": {"type": "string", "minLength": 1},
+		"args": {"type": "array", "items": {"type": "string"}},
+		"dir": {"type": "string"},
+		"env": {"type": "array", "items": {"type": "string", "pattern": "^[^=]+="}},
+		"max_output_bytes": {"type": "integer", "minimum": 1}
+	},
+	"required": ["command"],
+	"additionalProperties": false
+}`
+
+// ExecHandlerBuilder builds a Handler runs the command of the ExecConf for every request,
+// the Data is written to the stdin as JSON, the JSON stdout is decoded as the Data, an empty stdout means null,
+// the Meta of the request is passed through.
+// A non-zero exit fails with ErrHandleFailed with the stderr, a stdout more than the MaxOutputBytes fails with ErrExecOutputTooLarge,
+// the process and its children are killed when the ctx of the Pipe is done.
+// It is not built-in, since a conf using it can run any command,
+// register it by a HandlerBuilderGetter explicitly, e.g. MapHandlerBuilderGetter{"exec": ExecHandlerBuilder}.
+var ExecHandlerBuilder = WithConfSchema(NewTypedHandlerBuilder(func(conf ExecConf) (Handler, error) {
+	if conf.MaxOutputBytes < 1 {
+		return nil, fmt.Errorf("%w: max_output_bytes: less than 1", ErrBuilderConfFieldInvalid)
+	}
+	return execHandler{conf: conf}, nil
+}), execConfSchema)
+
+type execHandler struct {
+	conf ExecConf
+}
+
+// Handle implements the Handler.
+func (h execHandler) Handle(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+	res := &HandleRes{}
+	if reqRes != nil {
+		res.Meta = reqRes.Meta
+		res.Data = reqRes.Data
+	}
+	stdin, err := json.Marshal(res.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, h.conf.Command, h.conf.Args...)
+	cmd.Dir = h.conf.Dir
+	if len(h.conf.Env) > 0 {
+		cmd.Env = append(os.Environ(), h.conf.Env...)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitedBuffer{max: h.conf.MaxOutputBytes}
+	stderr := &limitedBuffer{max: execErrOutputLimit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = execWaitDelay
+	killProcessGroupOnCancel(cmd)
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%w: %s: %v: %s", ErrHandleFailed, h.conf.Command, err, bytes.TrimSpace(stderr.buf.Bytes()))
+		}
+		return nil, fmt.Errorf("%s: %w", h.conf.Command, err)
+	}
+
+	if stdout.exceeded {
+		return nil, fmt.Errorf("%w: %s: stdout more than %d bytes", ErrExecOutputTooLarge, h.conf.Command, h.conf.MaxOutputBytes)
+	}
+	res.Data = nil
+	if output := bytes.TrimSpace(stdout.buf.Bytes()); len(output) > 0 {
+		if err := json.Unmarshal(output, &res.Data); err != nil {
+			return nil, fmt.Errorf("%s: decode stdout: %w", h.conf.Command, err)
+		}
+	}
+	return res, nil
+}
+
+// limitedBuffer keeps at most max bytes written into it and drops the rest,
+// it never fails the Write, so the process is not blocked by a full pipe.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - int64(b.buf.Len()); int64(n) > room {
+		b.exceeded = true
+		p = p[:room]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
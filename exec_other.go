@@ -0,0 +1,10 @@
+//go:build !unix
+
+package pipeline
+
+import (
+	"os/exec"
+)
+
+// killProcessGroupOnCancel keeps the default behavior of exec.CommandContext, only the process itself is killed.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
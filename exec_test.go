@@ -0,0 +1,189 @@
+//go:build unix
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testExecHandlerBuilders = MapHandlerBuilderGetter{"exec": ExecHandlerBuilder}
+
+func TestExecHandlerBuilder(t *testing.T) {
+	tt := []struct {
+		caseName string
+		conf     map[string]interface{}
+		data     interface{}
+		want     interface{}
+		err      error
+		errMsg   string
+	}{
+		{
+			caseName: "echo stdin",
+			conf:     map[string]interface{}{"command": "cat"},
+			data:     map[string]interface{}{"n": float64(1)},
+			want:     map[string]interface{}{"n": 1},
+		},
+		{
+			caseName: "args and env",
+			conf: map[string]interface{}{
+				"command": "sh",
+				"args":    []interface{}{"-c", `cat > /dev/null; echo "{\"name\": \"$NAME\"}"`},
+				"env":     []interface{}{"NAME=foo"},
+			},
+			want: map[string]interface{}{"name": "foo"},
+		},
+		{
+			caseName: "empty stdout",
+			conf:     map[string]interface{}{"command": "true"},
+			data:     "foo",
+		},
+		{
+			caseName: "non-zero exit",
+			conf:     map[string]interface{}{"command": "sh", "args": []interface{}{"-c", "echo bad input >&2; exit 3"}},
+			err:      ErrHandleFailed,
+			errMsg:   "exit status 3: bad input",
+		},
+		{
+			caseName: "stdout too large",
+			conf:     map[string]interface{}{"command": "sh", "args": []interface{}{"-c", "yes 1 | head -c 2048"}, "max_output_bytes": float64(1024)},
+			err:      ErrExecOutputTooLarge,
+		},
+		{
+			caseName: "stdout at the limit",
+			conf:     map[string]interface{}{"command": "sh", "args": []interface{}{"-c", "printf '%1024d' 1"}, "max_output_bytes": float64(1024)},
+			want:     1,
+		},
+		{
+			caseName: "stderr capped",
+			conf:     map[string]interface{}{"command": "sh", "args": []interface{}{"-c", "yes bad | head -c 1048576 >&2; exit 3"}},
+			err:      ErrHandleFailed,
+			errMsg:   "exit status 3: bad",
+		},
+		{
+			caseName: "invalid stdout",
+			conf:     map[string]interface{}{"command": "echo", "args": []interface{}{"not json"}},
+			errMsg:   "decode stdout",
+		},
+		{
+			caseName: "command not found",
+			conf:     map[string]interface{}{"command": "go-pipeline-not-found"},
+			errMsg:   "not found",
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			pipe, err := NewSinglePipe(PipeConf{Timeout: 1000, Required: true, HandlerBuilderName: "exec", HandlerBuilderConf: item.conf}, testExecHandlerBuilders, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			meta := map[string]interface{}{"k": "v"}
+			res, err := pipe.Handle(context.Background(), &HandleRes{Meta: meta, Data: item.data})
+			if item.err != nil || item.errMsg != "" {
+				if item.err != nil && !errors.Is(err, item.err) {
+					t.Errorf("err: want=%v, got=%v", item.err, err)
+				}
+				if err == nil || !strings.Contains(err.Error(), item.errMsg) {
+					t.Errorf("err: want %q, got=%v", item.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(HandleRes{Status: HandleStatusOK, Meta: meta, Data: item.want}, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestExecHandlerBuilder_KillProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	conf := map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", `sleep 10 & echo $! > "$PID_FILE"; wait`},
+		"env":     []interface{}{"PID_FILE=" + pidFile},
+	}
+	handler, err := ExecHandlerBuilder.Build(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	startTime := time.Now()
+	if _, err := handler.Handle(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err: want=%v, got=%v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(startTime); elapsed >= execWaitDelay {
+		t.Errorf("elapsed: want < %v, got=%v", execWaitDelay, elapsed)
+	}
+
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); processAlive(pid); {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d is still alive", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processAlive checks the /proc/<pid>/stat, a zombie is not alive.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestExecHandlerBuilder_Build(t *testing.T) {
+	confs := []map[string]interface{}{
+		{},
+		{"command": "cat", "env": []interface{}{"NO_VALUE"}},
+		{"command": "cat", "shell": true},
+		{"command": "cat", "max_output_bytes": float64(0)},
+	}
+	for _, conf := range confs {
+		_, err := NewSinglePipe(PipeConf{Timeout: 20, Required: true, HandlerBuilderName: "exec", HandlerBuilderConf: conf}, testExecHandlerBuilders, nil)
+		if !errors.Is(err, ErrBuilderConfSchemaMismatch) {
+			t.Errorf("conf %v: want=%v, got=%v", conf, ErrBuilderConfSchemaMismatch, err)
+		}
+	}
+}
+
+func TestExecHandlerBuilder_NotBuiltin(t *testing.T) {
+	jsonConf := `[{"handler_builder_name":"exec","handler_builder_conf":{"command":"true"},"timeout":20,"required":true}]`
+	if _, err := NewLineByJSON(jsonConf, nil, nil); !errors.Is(err, ErrHandlerBuilderNotFound) {
+		t.Errorf("err: want=%v, got=%v", ErrHandlerBuilderNotFound, err)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 4}
+	for _, p := range []string{"ab", "cde", "fg"} {
+		if n, err := b.Write([]byte(p)); n != len(p) || err != nil {
+			t.Errorf("write %q: want=%v, got=%v, %v", p, len(p), n, err)
+		}
+	}
+	if got := b.buf.String(); got != "abcd" || !b.exceeded {
+		t.Errorf("want=%q exceeded, got=%q, %v", "abcd", got, b.exceeded)
+	}
+}
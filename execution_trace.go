@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExecutionTrace records the execution of a Line, it can be encoded into JSON for debugging.
+type ExecutionTrace struct {
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Duration  time.Duration `json:"duration"` // in nanosecond
+	Error     string        `json:"error,omitempty"`
+	Steps     []*TraceStep  `json:"steps"`
+	Output    *HandleRes    `json:"output"`
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// TraceStep records the execution of a Pipe, the Children are the steps of the Pipes inside it,
+// e.g. the branches of a parallel Pipe.
+// A step not finished when the Line returns has a zero EndTime, e.g. a branch canceled by ParallelModeRace.
+type TraceStep struct {
+	Desc        string        `json:"desc"`
+	Type        PipeType      `json:"type"`
+	Path        string        `json:"path,omitempty"`
+	StartTime   time.Time     `json:"start_time"`
+	EndTime     time.Time     `json:"end_time"`
+	Duration    time.Duration `json:"duration"` // in nanosecond
+	Status      HandleStatus  `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	DefaultUsed bool          `json:"default_used"`
+	Input       *HandleRes    `json:"input"`
+	Output      *HandleRes    `json:"output"`
+	Children    []*TraceStep  `json:"children,omitempty"`
+}
+
+type traceRecorderKey struct{}
+
+// traceRecorder appends the steps into the parent step, or into the trace if the parent is nil.
+// All the recorders of a trace share the trace.mu.
+type traceRecorder struct {
+	trace  *ExecutionTrace
+	parent *TraceStep
+}
+
+func traceRecorderFromContext(ctx context.Context) *traceRecorder {
+	recorder, _ := ctx.Value(traceRecorderKey{}).(*traceRecorder)
+	return recorder
+}
+
+// start appends a new step of the pipe, returns the ctx for the Pipes inside it and a func to finish the step.
+func (r *traceRecorder) start(ctx context.Context, pipe Pipe, reqRes *HandleRes) (context.Context, func(res *HandleRes, err error, defaultUsed bool)) {
+	step := &TraceStep{
+		Desc:      pipe.Conf.Desc,
+		Type:      pipe.Type,
+		Path:      pipe.Path,
+		StartTime: time.Now(),
+		Input:     snapshot(reqRes),
+	}
+
+	r.trace.mu.Lock()
+	if !r.trace.closed {
+		if r.parent == nil {
+			r.trace.Steps = append(r.trace.Steps, step)
+		} else {
+			r.parent.Children = append(r.parent.Children, step)
+		}
+	}
+	r.trace.mu.Unlock()
+
+	ctx = context.WithValue(ctx, traceRecorderKey{}, &traceRecorder{trace: r.trace, parent: step})
+	return ctx, func(res *HandleRes, err error, defaultUsed bool) {
+		output := snapshot(res)
+		endTime := time.Now()
+
+		r.trace.mu.Lock()
+		defer r.trace.mu.Unlock()
+		if r.trace.closed {
+			return
+		}
+		step.EndTime = endTime
+		step.Duration = endTime.Sub(step.StartTime)
+		step.Output = output
+		step.DefaultUsed = defaultUsed
+		if res != nil {
+			step.Status = res.Status
+		}
+		if err != nil {
+			step.Error = err.Error()
+			if step.Status == 0 {
+				step.Status = HandleStatusFailed
+			}
+		}
+	}
+}
+
+// snapshot copies the res deeply.
+func snapshot(res *HandleRes) *HandleRes {
+	if res == nil {
+		return nil
+	}
+	copied, _ := res.Copy()
+	return copied
+}
+
+// HandleTraced calls l.Pipes one by one like Handle, records every step recursively into a ExecutionTrace.
+// The returned trace is complete even if the err is not nil.
+func (l Line) HandleTraced(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, trace *ExecutionTrace, err error) {
+	trace = &ExecutionTrace{StartTime: time.Now(), Steps: make([]*TraceStep, 0, len(l.Pipes))}
+	ctx = context.WithValue(ctx, traceRecorderKey{}, &traceRecorder{trace: trace})
+
+	respRes, err = l.Handle(ctx, reqRes)
+
+	output := snapshot(respRes)
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.closed = true
+	trace.EndTime = time.Now()
+	trace.Duration = trace.EndTime.Sub(trace.StartTime)
+	trace.Output = output
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	return respRes, trace, err
+}
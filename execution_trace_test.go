@@ -0,0 +1,160 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+var testTraceJSONConf = `
+[
+    {
+        "desc": "square",
+        "ref_handler_id": "by_square",
+        "timeout": 20,
+        "required": true
+    },
+    [
+        {
+            "desc": "cubic",
+            "ref_handler_id": "by_cubic",
+            "timeout": 20,
+            "required": true
+        },
+        {
+            "desc": "fallback",
+            "ref_handler_id": "failed_unknown",
+            "timeout": 20,
+            "default_data": 0
+        }
+    ],
+    {
+        "desc": "failed",
+        "ref_handler_id": "failed_unknown",
+        "timeout": 20,
+        "required": true
+    },
+    {
+        "desc": "unreached",
+        "ref_handler_id": "by_square",
+        "timeout": 20,
+        "required": true
+    }
+]
+`
+
+func TestLine_HandleTraced(t *testing.T) {
+	line, err := NewLineByJSON(testTraceJSONConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, trace, err := line.HandleTraced(context.Background(), &HandleRes{Data: float64(2)})
+	if err == nil {
+		t.Fatal("err is nil")
+	}
+	if trace.Error != err.Error() {
+		t.Errorf("trace error: want=%v, got=%v", err.Error(), trace.Error)
+	}
+
+	type stepSummary struct {
+		Desc        string
+		Type        PipeType
+		Path        string
+		Status      HandleStatus
+		HasError    bool
+		DefaultUsed bool
+		Input       interface{}
+		Output      interface{}
+		Children    []stepSummary
+	}
+	var summarize func(steps []*TraceStep) []stepSummary
+	summarize = func(steps []*TraceStep) []stepSummary {
+		summaries := make([]stepSummary, 0, len(steps))
+		for _, step := range steps {
+			if step.EndTime.Before(step.StartTime) || step.Duration != step.EndTime.Sub(step.StartTime) {
+				t.Errorf("%s: bad timing", step.Path)
+			}
+			summary := stepSummary{
+				Desc:        step.Desc,
+				Type:        step.Type,
+				Path:        step.Path,
+				Status:      step.Status,
+				HasError:    step.Error != "",
+				DefaultUsed: step.DefaultUsed,
+				Input:       step.Input.Data,
+			}
+			if step.Output != nil {
+				summary.Output = step.Output.Data
+			}
+			if len(step.Children) > 0 {
+				// the branches of a parallel start in any order
+				children := make([]*TraceStep, len(step.Children))
+				for _, child := range step.Children {
+					for i, pipe := range line.Pipes[1].Handler.(*Parallel).Pipes {
+						if pipe.Path == child.Path {
+							children[i] = child
+						}
+					}
+				}
+				summary.Children = summarize(children)
+			}
+			summaries = append(summaries, summary)
+		}
+		return summaries
+	}
+
+	want := []stepSummary{
+		{Desc: "square", Type: PipeTypeSingle, Path: "$[0]", Status: HandleStatusOK, Input: 2, Output: 4},
+		{
+			Type: PipeTypeParallel, Path: "$[1]", Status: HandleStatusOK, Input: 4, Output: []interface{}{64, 0},
+			Children: []stepSummary{
+				{Desc: "cubic", Type: PipeTypeSingle, Path: "$[1][0]", Status: HandleStatusOK, Input: 4, Output: 64},
+				{Desc: "fallback", Type: PipeTypeSingle, Path: "$[1][1]", Status: HandleStatusFailed, DefaultUsed: true, Input: 4, Output: 0},
+			},
+		},
+		{Desc: "failed", Type: PipeTypeSingle, Path: "$[2]", Status: HandleStatusFailed, HasError: true, Input: []interface{}{64, 0}},
+	}
+	if text, ok := diff(want, summarize(trace.Steps)); !ok {
+		t.Error("steps diff:\n", text)
+	}
+
+	b, err := json.Marshal(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(b))
+}
+
+func TestLine_HandleTraced_Race(t *testing.T) {
+	line := Line{Pipes: []Pipe{{
+		Type: PipeTypeParallel,
+		Handler: &Parallel{
+			Mode: ParallelModeRace,
+			Pipes: []Pipe{
+				{Type: PipeTypeSingle, Conf: PipeConf{Desc: "fast", Timeout: 100, Required: true}, Handler: bySquare},
+				{Type: PipeTypeSingle, Conf: PipeConf{Desc: "slow", Timeout: 1000, Required: true}, Handler: delay1000},
+			},
+		},
+	}}}
+
+	noLeak(t, func() {
+		_, trace, err := line.HandleTraced(context.Background(), &HandleRes{Data: float64(2)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := json.Marshal(trace); err != nil {
+			t.Fatal(err)
+		}
+		// the slow step may be unfinished or even not started when the Line returns
+		fastFinished := false
+		for _, child := range trace.Steps[0].Children {
+			if child.Desc == "fast" && !child.EndTime.IsZero() {
+				fastFinished = true
+			}
+		}
+		if !fastFinished {
+			t.Error("fast step is not finished")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// exprMaxNodes limits the size of an expression.
+const exprMaxNodes = 1000
+
+// exprEnv is the variables of an expression evaluated against a HandleRes.
+type exprEnv struct {
+	Data    interface{}            `expr:"data"`
+	Meta    map[string]interface{} `expr:"meta"`
+	Status  string                 `expr:"status"` // "ok", "timeout", "failed" or "unknown"
+	Message string                 `expr:"message"`
+}
+
+func newExprEnv(res *HandleRes) exprEnv {
+	if res == nil {
+		return exprEnv{Meta: map[string]interface{}{}, Status: HandleStatus(0).String()}
+	}
+	env := exprEnv{Data: res.Data, Meta: res.Meta, Status: res.Status.String(), Message: res.Message}
+	if env.Meta == nil {
+		env.Meta = map[string]interface{}{}
+	}
+	return env
+}
+
+type exprKey struct {
+	source string
+	asBool bool
+}
+
+// compiledExprs caches the compiled expressions keyed by their sources.
+var compiledExprs sync.Map
+
+// compileExpr compiles the source into a program evaluated against a HandleRes by runExpr,
+// it must return a bool if asBool is true. The unknown variables are rejected.
+func compileExpr(source string, asBool bool) (*vm.Program, error) {
+	key := exprKey{source: source, asBool: asBool}
+	if program, ok := compiledExprs.Load(key); ok {
+		return program.(*vm.Program), nil
+	}
+
+	opts := []expr.Option{expr.Env(exprEnv{}), expr.MaxNodes(exprMaxNodes)}
+	if asBool {
+		opts = append(opts, expr.AsBool())
+	}
+	program, err := expr.Compile(source, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidExpr, source, err)
+	}
+	compiledExprs.Store(key, program)
+	return program, nil
+}
+
+// runExpr evaluates the program against the res.
+func runExpr(program *vm.Program, res *HandleRes) (interface{}, error) {
+	out, err := expr.Run(program, newExprEnv(res))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExprEvalFailed, err)
+	}
+	return out, nil
+}
+
+// skip evaluates the pipe.Conf.SkipIf against the reqRes, returns false if it is empty.
+func (pipe Pipe) skip(reqRes *HandleRes) (bool, error) {
+	if pipe.Conf.SkipIf == "" {
+		return false, nil
+	}
+	program, err := compileExpr(pipe.Conf.SkipIf, true)
+	if err != nil {
+		return false, err
+	}
+	out, err := runExpr(program, reqRes)
+	if err != nil {
+		return false, err
+	}
+	skip, ok := out.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: %q returns a %T, want a bool", ErrExprEvalFailed, pipe.Conf.SkipIf, out)
+	}
+	return skip, nil
+}
+
+// transformHandlerBuilder builds a Handler returns the result of the "expr" as the Data, the Meta is passed through,
+// e.g. {"expr": "{\"total\": data.price * data.count, \"user\": meta.user}"}.
+var transformHandlerBuilder = WithConfSchema(NewTypedHandlerBuilder(func(conf struct {
+	Expr string `json:"expr" required:"true"`
+}) (Handler, error) {
+	program, err := compileExpr(conf.Expr, false)
+	if err != nil {
+		return nil, err
+	}
+	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		out, err := runExpr(program, reqRes)
+		if err != nil {
+			return nil, err
+		}
+		res := &HandleRes{Data: out}
+		if reqRes != nil {
+			res.Meta = reqRes.Meta
+		}
+		return res, nil
+	}), nil
+}), `{
+	"type": "object",
+	"properties": {"expr": {"type": "string", "minLength": 1}},
+	"required": ["expr"],
+	"additionalProperties": false
+}`)
@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompileExpr(t *testing.T) {
+	tt := []struct {
+		caseName string
+		source   string
+		asBool   bool
+		hasErr   bool
+	}{
+		{caseName: "bool", source: `data.n > 1 && meta.kind == "a" && status == "ok"`, asBool: true},
+		{caseName: "any", source: `{"n": data.n, "message": message}`},
+		{caseName: "syntax error", source: `data.n >`, hasErr: true},
+		{caseName: "unknown variable", source: `res.n > 1`, asBool: true, hasErr: true},
+		{caseName: "not bool", source: `"yes"`, asBool: true, hasErr: true},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			_, err := compileExpr(item.source, item.asBool)
+			if item.hasErr {
+				if !errors.Is(err, ErrInvalidExpr) {
+					t.Errorf("err: want=%v, got=%v", ErrInvalidExpr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCondition_Match_Expr(t *testing.T) {
+	res := &HandleRes{Status: HandleStatusOK, Meta: map[string]interface{}{"tenant": "acme"}, Data: map[string]interface{}{"age": float64(20)}}
+	tt := []struct {
+		caseName string
+		expr     string
+		res      *HandleRes
+		match    bool
+	}{
+		{caseName: "true", expr: `data.age >= 18 && meta.tenant == "acme"`, res: res, match: true},
+		{caseName: "false", expr: `status != "ok"`, res: res},
+		{caseName: "nil res", expr: `data == nil && status == "unknown"`, match: true},
+		{caseName: "eval failed", expr: `data.age + 1 > 0`},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			c := Condition{Expr: item.expr}
+			if err := c.compile("$"); err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Match(item.res); got != item.match {
+				t.Errorf("want=%v, got=%v", item.match, got)
+			}
+		})
+	}
+
+	c := Condition{Expr: `data.age >`}
+	if err := c.compile("$.when"); !errors.Is(err, ErrInvalidExpr) {
+		t.Errorf("err: want=%v, got=%v", ErrInvalidExpr, err)
+	}
+}
+
+func TestSinglePipe_Handle_SkipIf(t *testing.T) {
+	tt := []struct {
+		caseName string
+		pc       PipeConf
+		data     interface{}
+		res      HandleRes
+		hasErr   bool
+	}{
+		{
+			caseName: "skipped",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", SkipIf: "data > 10"},
+			data:     float64(20),
+			res:      HandleRes{Status: HandleStatusOK, Data: 20},
+		},
+		{
+			caseName: "not skipped",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", SkipIf: "data > 10"},
+			data:     float64(2),
+			res:      HandleRes{Status: HandleStatusOK, Data: 4},
+		},
+		{
+			caseName: "eval failed",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", SkipIf: "data.n > 10"},
+			data:     "foo",
+			hasErr:   true,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			pipe, err := NewSinglePipe(item.pc, nil, exampleHandlerGetter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := pipe.Handle(context.Background(), &HandleRes{Status: HandleStatusOK, Data: item.data})
+			if item.hasErr {
+				if !errors.Is(err, ErrExprEvalFailed) {
+					t.Errorf("err: want=%v, got=%v", ErrExprEvalFailed, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.res, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+
+	if _, err := NewSinglePipe(PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", SkipIf: "data +"}, nil, exampleHandlerGetter); !errors.Is(err, ErrInvalidExpr) {
+		t.Errorf("err: want=%v, got=%v", ErrInvalidExpr, err)
+	}
+}
+
+func TestTransformHandlerBuilder(t *testing.T) {
+	jsonConf := `[
+		{"handler_builder_name":"transform","handler_builder_conf":{"expr":"{\"total\": data.price * data.count, \"user\": meta.user}"},"timeout":20,"required":true},
+		{"type":"switch","cases":[{"when":{"expr":"data.total > 10"},"pipes":[]}]}
+	]`
+	line, err := NewLineByJSON(jsonConf, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := map[string]interface{}{"user": "foo"}
+	res, err := line.Handle(context.Background(), &HandleRes{Meta: meta, Data: map[string]interface{}{"price": float64(3), "count": float64(4)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(HandleRes{Status: HandleStatusOK, Meta: meta, Data: map[string]interface{}{"total": 12, "user": "foo"}}, res); !ok {
+		t.Error("res diff:\n", text)
+	}
+
+	errConfs := []string{
+		`[{"handler_builder_name":"transform","handler_builder_conf":{"expr":"data +"},"timeout":20,"required":true}]`,
+		`[{"type":"switch","cases":[{"when":{"expr":"status"},"pipes":[]}]}]`,
+		`[{"ref_handler_id":"by_square","timeout":20,"required":true,"skip_if":"unknown > 1"}]`,
+	}
+	for _, jsonConf := range errConfs {
+		if _, err := NewLineByJSON(jsonConf, nil, exampleHandlerGetter); !errors.Is(err, ErrInvalidExpr) {
+			t.Errorf("err: want=%v, got=%v", ErrInvalidExpr, err)
+		}
+	}
+	if _, err := NewLineByJSON(`[{"handler_builder_name":"transform","timeout":20,"required":true}]`, nil, nil); !errors.Is(err, ErrBuilderConfSchemaMismatch) {
+		t.Errorf("err: want=%v, got=%v", ErrBuilderConfSchemaMismatch, err)
+	}
+}
+
+func TestParallel_Handle_SkippedBranch(t *testing.T) {
+	for _, mode := range []string{`"mode":"race"`, `"mode":"quorum","quorum":1`, `"mode":"all"`} {
+		t.Run(mode, func(t *testing.T) {
+			jsonConf := `[{"type":"parallel",` + mode + `,"pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true,"skip_if":"true"}]}]`
+			line, err := NewLineByJSON(jsonConf, nil, exampleHandlerGetter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != HandleStatusOK {
+				t.Errorf("status: want=%v, got=%v", HandleStatusOK, res.Status)
+			}
+		})
+	}
+}
@@ -1,5 +1,13 @@
 module github.com/Focinfi/go-pipeline
 
-go 1.14
+go 1.21.0
 
-require github.com/nsf/jsondiff v0.0.0-20190712045011-8443391ee9b6
+require (
+	github.com/expr-lang/expr v1.17.8
+	github.com/nsf/jsondiff v0.0.0-20190712045011-8443391ee9b6
+	github.com/pelletier/go-toml/v2 v2.4.3
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/text v0.14.0 // indirect
@@ -2,7 +2,6 @@ package pipeline
 
 import (
 	"context"
-	"encoding/json"
 )
 
 type HandleStatus int
@@ -13,6 +12,18 @@ const (
 	HandleStatusFailed  HandleStatus = 3
 )
 
+func (status HandleStatus) String() string {
+	switch status {
+	case HandleStatusOK:
+		return "ok"
+	case HandleStatusTimeout:
+		return "timeout"
+	case HandleStatusFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
 // HandleRes acts as the input/output of Handler.
 type HandleRes struct {
 	Status  HandleStatus           `json:"status"`
@@ -21,18 +32,13 @@ type HandleRes struct {
 	Data    interface{}            `json:"data"`
 }
 
-// Copy copy the res using json.Marshal/json.Unmarshal.
+// Copy copies the res deeply by DeepCopy, the types of the Meta and the Data are preserved.
+// The error is always nil, it is kept for compatibility.
 func (res *HandleRes) Copy() (*HandleRes, error) {
-	var resCopy HandleRes
-	bytes, err := json.Marshal(res)
-	if err != nil {
-		return nil, err
+	if res == nil {
+		return &HandleRes{}, nil
 	}
-	err = json.Unmarshal(bytes, &resCopy)
-	if err != nil {
-		return nil, err
-	}
-	return &resCopy, nil
+	return DeepCopy(res).(*HandleRes), nil
 }
 
 type Handler interface {
@@ -73,6 +79,26 @@ func (m MapHandlerBuilderGetter) GetHandlerBuilderOK(id string) (HandlerBuilder,
 	return builder, ok
 }
 
+// Names of the built-in HandlerBuilders.
+const (
+	HandlerBuilderTransform = "transform"
+)
+
+var builtinHandlerBuilders MapHandlerBuilderGetter = map[string]HandlerBuilder{
+	HandlerBuilderTransform: transformHandlerBuilder,
+}
+
+// getHandlerBuilder finds the HandlerBuilder with the name in the handlerBuilders, then the built-in ones,
+// so a given HandlerBuilder can override a built-in one with the same name.
+func getHandlerBuilder(handlerBuilders HandlerBuilderGetter, name string) (HandlerBuilder, bool) {
+	if handlerBuilders != nil {
+		if builder, ok := handlerBuilders.GetHandlerBuilderOK(name); ok {
+			return builder, true
+		}
+	}
+	return builtinHandlerBuilders.GetHandlerBuilderOK(name)
+}
+
 type HandlerGetter interface {
 	GetHandlerOK(name string) (Handler, bool)
 }
@@ -16,31 +16,23 @@ var exampleHandlerBuilderGetter MapHandlerBuilderGetter = map[string]HandlerBuil
 
 var (
 	delay10, _ = handlerBuilderDelay.Build(map[string]interface{}{
-		"delay": time.Millisecond * 10,
+		"delay": "10ms",
 	})
 	delay1000, _ = handlerBuilderDelay.Build(map[string]interface{}{
-		"delay": time.Millisecond * 1000,
+		"delay": "1s",
 	})
 	failedUnknown, _ = handlerBuilderFailed.Build(map[string]interface{}{
 		"err": errUnknown,
 	})
 	bySquare, _ = handlerBuilderBy.Build(map[string]interface{}{
-		"handle": func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
-			data := reqRes.Data.(float64) * reqRes.Data.(float64)
-			return &HandleRes{
-				Meta: reqRes.Meta,
-				Data: data,
-			}, nil
-		},
+		"handle": FromTyped(TypedHandlerFunc[float64, float64](func(ctx context.Context, in float64) (float64, error) {
+			return in * in, nil
+		})).Handle,
 	})
 	byCubic, _ = handlerBuilderBy.Build(map[string]interface{}{
-		"handle": func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
-			data := reqRes.Data.(float64) * reqRes.Data.(float64) * reqRes.Data.(float64)
-			return &HandleRes{
-				Meta: reqRes.Meta,
-				Data: data,
-			}, nil
-		},
+		"handle": FromTyped(TypedHandlerFunc[float64, float64](func(ctx context.Context, in float64) (float64, error) {
+			return in * in * in, nil
+		})).Handle,
 	})
 )
 
@@ -52,22 +44,29 @@ var exampleHandlerGetter MapHandlerGetter = map[string]Handler{
 	"by_cubic":       byCubic,
 }
 
-var handlerBuilderDelay = HandlerBuilderFunc(func(conf map[string]interface{}) (Handler, error) {
+var handlerBuilderDelay = NewTypedHandlerBuilder(func(conf struct {
+	Delay time.Duration `json:"delay" required:"true"`
+}) (Handler, error) {
 	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
-		time.Sleep(conf["delay"].(time.Duration))
-		return reqRes, nil
+		select {
+		case <-time.After(conf.Delay):
+			return reqRes, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}), nil
 })
 
-var handlerBuilderFailed = HandlerBuilderFunc(func(conf map[string]interface{}) (Handler, error) {
+var handlerBuilderFailed = NewTypedHandlerBuilder(func(conf struct {
+	Err error `json:"err" required:"true"`
+}) (Handler, error) {
 	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
-		return reqRes, conf["err"].(error)
+		return reqRes, conf.Err
 	}), nil
 })
 
-var handlerBuilderBy = HandlerBuilderFunc(func(conf map[string]interface{}) (Handler, error) {
-	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
-		f := conf["handle"].(func(context.Context, *HandleRes) (*HandleRes, error))
-		return f(ctx, reqRes)
-	}), nil
+var handlerBuilderBy = NewTypedHandlerBuilder(func(conf struct {
+	Handle func(context.Context, *HandleRes) (*HandleRes, error) `json:"handle" required:"true"`
+}) (Handler, error) {
+	return HandlerFunc(conf.Handle), nil
 })
@@ -0,0 +1,240 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Decodings of the response body, see HTTPConf.
+const (
+	HTTPDecodeJSON = "json" // decodes the JSON body as the Data
+	HTTPDecodeText = "text" // uses the body as a string Data
+	HTTPDecodeNone = "none" // drops the body, passes the Data of the request through
+)
+
+// httpErrBodyLimit limits the length of the response body in the error of a unexpected status.
+const httpErrBodyLimit = 512
+
+// HTTPConf is the conf of the HandlerBuilder created by NewHTTPHandlerBuilder.
+// The URL, the values of the Headers and the Body are text/template templates executed with
+// .Data and .Meta of the request, e.g. "https://api.example.com/users/{{.Data.id}}",
+// the functions json, query and path encode a value as JSON, a query value and a path segment.
+// A missing key of a map fails the execution.
+type HTTPConf struct {
+	Method         string            `json:"method" default:"GET"`
+	URL            string            `json:"url" required:"true"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`            // empty means no body, Content-Type is application/json by default
+	ExpectedStatus []int             `json:"expected_status"` // empty means any 2xx
+	Decode         string            `json:"decode" default:"json"`
+
+	MaxResponseBytes int64 `json:"max_response_bytes" default:"1048576"` // a larger response body fails with ErrHTTPResponseTooLarge
+}
+
+const httpConfSchema = `{
+	"type": "object",
+	"properties": {
+		"method": {"type": "string", "minLength": 1},
+		"url": {"type": "string", "minLength": 1},
+		"headers": {"type": "object", "additionalProperties": {"type": "string"}},
+		"body": {"type": "string"},
+		"expected_status": {"type": "array", "items": {"type": "integer", "minimum": 100, "maximum": 599}},
+		"decode": {"enum": ["json", "text", "none"]},
+		"max_response_bytes": {"type": "integer", "minimum": 1}
+	},
+	"required": ["url"],
+	"additionalProperties": false
+}`
+
+var httpTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"query": url.QueryEscape,
+	"path":  url.PathEscape,
+}
+
+// NewHTTPHandlerBuilder creates a HandlerBuilder with the HTTPConf, which sends the requests by the client,
+// http.DefaultClient is used if the client is nil.
+// The request is canceled with the ctx of the Pipe, a unexpected status fails with ErrHTTPUnexpectedStatus.
+// The Meta of the request is passed through.
+// The URL in the errors is redacted to the scheme, the host and the path, since the query may contain secrets.
+// It is not built-in, since a conf using it can send requests to any URL,
+// register it by a HandlerBuilderGetter explicitly, e.g. MapHandlerBuilderGetter{"http": NewHTTPHandlerBuilder(nil)}.
+func NewHTTPHandlerBuilder(client *http.Client) HandlerBuilder {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return WithConfSchema(NewTypedHandlerBuilder(func(conf HTTPConf) (Handler, error) {
+		return newHTTPHandler(client, conf)
+	}), httpConfSchema)
+}
+
+type httpHandler struct {
+	client  *http.Client
+	conf    HTTPConf
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template // nil means no body
+}
+
+func newHTTPHandler(client *http.Client, conf HTTPConf) (*httpHandler, error) {
+	switch conf.Decode {
+	case HTTPDecodeJSON, HTTPDecodeText, HTTPDecodeNone:
+	default:
+		return nil, fmt.Errorf("%w: decode: unknown %q", ErrBuilderConfFieldInvalid, conf.Decode)
+	}
+	if conf.MaxResponseBytes < 1 {
+		return nil, fmt.Errorf("%w: max_response_bytes: less than 1", ErrBuilderConfFieldInvalid)
+	}
+
+	h := &httpHandler{client: client, conf: conf, headers: make(map[string]*template.Template, len(conf.Headers))}
+	h.conf.Method = strings.ToUpper(conf.Method)
+
+	var err error
+	if h.url, err = parseHTTPTemplate("url", conf.URL); err != nil {
+		return nil, err
+	}
+	for k, v := range conf.Headers {
+		if h.headers[k], err = parseHTTPTemplate("headers."+k, v); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Body != "" {
+		if h.body, err = parseHTTPTemplate("body", conf.Body); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func parseHTTPTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(httpTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBuilderConfFieldInvalid, name, err)
+	}
+	return t, nil
+}
+
+// Handle implements the Handler.
+func (h *httpHandler) Handle(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+	var tmplData struct {
+		Data interface{}
+		Meta map[string]interface{}
+	}
+	if reqRes != nil {
+		tmplData.Data, tmplData.Meta = reqRes.Data, reqRes.Meta
+	}
+
+	u, err := executeHTTPTemplate(h.url, tmplData)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if h.body != nil {
+		b, err := executeHTTPTemplate(h.body, tmplData)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, h.conf.Method, u, body)
+	if err != nil {
+		return nil, redactURLErr(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, t := range h.headers {
+		v, err := executeHTTPTemplate(t, tmplData)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, redactURLErr(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, h.conf.MaxResponseBytes+1))
+	if err != nil {
+		return nil, redactURLErr(err)
+	}
+	if int64(len(respBody)) > h.conf.MaxResponseBytes {
+		return nil, fmt.Errorf("%w: %s %s: more than %d bytes", ErrHTTPResponseTooLarge, h.conf.Method, redactURL(req.URL), h.conf.MaxResponseBytes)
+	}
+	if !h.expected(resp.StatusCode) {
+		if len(respBody) > httpErrBodyLimit {
+			respBody = respBody[:httpErrBodyLimit]
+		}
+		return nil, fmt.Errorf("%w: %s %s: %d: %s", ErrHTTPUnexpectedStatus, h.conf.Method, redactURL(req.URL), resp.StatusCode, respBody)
+	}
+
+	res := &HandleRes{Meta: tmplData.Meta}
+	switch h.conf.Decode {
+	case HTTPDecodeJSON:
+		if len(respBody) > 0 {
+			if err := json.Unmarshal(respBody, &res.Data); err != nil {
+				return nil, fmt.Errorf("decode response: %w", err)
+			}
+		}
+	case HTTPDecodeText:
+		res.Data = string(respBody)
+	default:
+		res.Data = tmplData.Data
+	}
+	return res, nil
+}
+
+// expected checks the code by the h.conf.ExpectedStatus, any 2xx by default.
+func (h *httpHandler) expected(code int) bool {
+	if len(h.conf.ExpectedStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, status := range h.conf.ExpectedStatus {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+func executeHTTPTemplate(t *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// redactURL returns the u without the user info, the query and the fragment.
+func redactURL(u *url.URL) string {
+	redacted := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath}
+	return redacted.String()
+}
+
+// redactURLErr redacts the URL of the err if it is a *url.Error.
+func redactURLErr(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := *urlErr
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		redacted.URL = redactURL(u)
+	} else {
+		redacted.URL = "<invalid url>"
+	}
+	return &redacted
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limiter limits the number of the in-flight handler calls,
+// a Limiter can be shared by many Lines to enforce a process-wide limit.
+// A nil *Limiter means no limit.
+// A Pipe holding a slot marks its ctx, so a Line used as the handler of another Line with the same Limiter
+// runs within the slot of the outer Pipe instead of waiting for another one, which would deadlock with a small n.
+type Limiter struct {
+	sem chan struct{} // nil means no limit
+}
+
+// NewLimiter creates a Limiter allows at most n in-flight calls, 0 means no limit like the max_concurrency.
+// It panics if the n is negative.
+func NewLimiter(n int) *Limiter {
+	if n < 0 {
+		panic(fmt.Sprintf("pipeline: NewLimiter: negative n: %d", n))
+	}
+	if n == 0 {
+		return &Limiter{}
+	}
+	return &Limiter{sem: make(chan struct{}, n)}
+}
+
+// Acquire takes a slot, blocks until a slot is available or the ctx is done.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if !acquire(ctx, l.semaphore()) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Release gives back the slot taken by Acquire.
+func (l *Limiter) Release() {
+	release(l.semaphore())
+}
+
+// InFlight returns the number of the taken slots, always 0 if there is no limit.
+func (l *Limiter) InFlight() int {
+	return len(l.semaphore())
+}
+
+// heldLimiterKey is the ctx key marking the Limiter l is held by a caller.
+type heldLimiterKey struct {
+	l *Limiter
+}
+
+// held reports whether a slot of the l is held by a caller of the ctx.
+func (l *Limiter) held(ctx context.Context) bool {
+	return ctx.Value(heldLimiterKey{l: l}) != nil
+}
+
+// markHeld returns a ctx marking a slot of the l is held.
+func (l *Limiter) markHeld(ctx context.Context) context.Context {
+	return context.WithValue(ctx, heldLimiterKey{l: l}, true)
+}
+
+func (l *Limiter) semaphore() chan struct{} {
+	if l == nil {
+		return nil
+	}
+	return l.sem
+}
+
+// acquire takes a slot of the sem, returns false if the ctx is done before that.
+// A nil sem means no limit.
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	if sem == nil {
+		return true
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release gives back the slot taken by acquire.
+func release(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
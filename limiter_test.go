@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyRecorder returns a Handler which sleeps for d,
+// the returned pointer holds the max number of the concurrent calls of it.
+func concurrencyRecorder(d time.Duration) (Handler, *int32) {
+	var running, maxRunning int32
+	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(d)
+		return reqRes, nil
+	}), &maxRunning
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(1)
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if limiter.InFlight() != 1 {
+		t.Errorf("in flight: want=%v, got=%v", 1, limiter.InFlight())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := limiter.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err: want=%v, got=%v", context.DeadlineExceeded, err)
+	}
+
+	limiter.Release()
+	if limiter.InFlight() != 0 {
+		t.Errorf("in flight: want=%v, got=%v", 0, limiter.InFlight())
+	}
+}
+
+func TestLimiter_NoLimit(t *testing.T) {
+	for _, limiter := range []*Limiter{NewLimiter(0), nil} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		for i := 0; i < 3; i++ {
+			if err := limiter.Acquire(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cancel()
+		if limiter.InFlight() != 0 {
+			t.Errorf("in flight: want=%v, got=%v", 0, limiter.InFlight())
+		}
+		for i := 0; i < 3; i++ {
+			limiter.Release()
+		}
+	}
+}
+
+func TestNewLimiter_Negative(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	NewLimiter(-1)
+}
+
+func TestNewLineByJSON_WithLimiter(t *testing.T) {
+	handler, maxRunning := concurrencyRecorder(time.Millisecond * 10)
+	handlers := MapHandlerGetter{"recorder": handler}
+	conf := `[[
+		{"ref_handler_id":"recorder","timeout":1000,"required":true},
+		{"ref_handler_id":"recorder","timeout":1000,"required":true},
+		{"ref_handler_id":"recorder","timeout":1000,"required":true}
+	]]`
+
+	limiter := NewLimiter(2)
+	lines := make([]*Line, 3)
+	for i := range lines {
+		line, err := NewLineByJSON(conf, nil, handlers, WithLimiter(limiter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = line
+	}
+
+	var wg sync.WaitGroup
+	for _, line := range lines {
+		wg.Add(1)
+		go func(line *Line) {
+			defer wg.Done()
+			if _, err := line.Handle(context.Background(), &HandleRes{}); err != nil {
+				t.Error(err)
+			}
+		}(line)
+	}
+	wg.Wait()
+
+	if *maxRunning != 2 {
+		t.Errorf("max running: want=%v, got=%v", 2, *maxRunning)
+	}
+}
+
+func TestPipe_Handle_Limiter_Timeout(t *testing.T) {
+	limiter := NewLimiter(1)
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer limiter.Release()
+
+	pipe := Pipe{
+		Type:    PipeTypeSingle,
+		Conf:    PipeConf{Desc: "limited", Timeout: 10, Required: false, DefaultData: -1},
+		Handler: bySquare,
+		Limiter: limiter,
+	}
+	res, err := pipe.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != HandleStatusTimeout {
+		t.Errorf("status: want=%v, got=%v", HandleStatusTimeout, res.Status)
+	}
+}
+
+func TestNewLineByJSON_WithLimiter_Nested(t *testing.T) {
+	limiter := NewLimiter(1)
+	inner, err := NewLineByJSON(`[{"ref_handler_id":"by_square","timeout":50,"required":true}]`, nil, exampleHandlerGetter, WithLimiter(limiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := MapHandlerGetter{"inner": inner, "by_square": exampleHandlerGetter["by_square"]}
+	outer, err := NewLineByJSON(`[
+		{"ref_handler_id":"inner","timeout":50,"required":true},
+		[
+			{"ref_handler_id":"by_square","timeout":50,"required":true},
+			{"ref_handler_id":"inner","timeout":50,"required":true}
+		]
+	]`, nil, handlers, WithLimiter(limiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := outer.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff([]interface{}{float64(16), float64(16)}, res.Data); !ok {
+		t.Error("data diff:\n", text)
+	}
+	if limiter.InFlight() != 0 {
+		t.Errorf("in flight: want=%v, got=%v", 0, limiter.InFlight())
+	}
+}
@@ -3,15 +3,24 @@ package pipeline
 import (
 	"context"
 	"encoding/json"
-	"strings"
+	"fmt"
 )
 
 type Line struct {
 	Pipes []Pipe `json:"pipes"`
+
+	Observer Observer `json:"-"` // observes the executions of the Line, nil means no observing
 }
 
 // Handle calls l.Pipes one by one, returns immediately when one Pipe.Handle returns error.
+// The execution is observed by the l.Observer.
 func (l Line) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, err error) {
+	if l.Observer != nil {
+		var finish func(res *HandleRes, err error)
+		ctx, finish = l.Observer.StartLine(ctx, l, reqRes)
+		defer func() { finish(respRes, err) }()
+	}
+
 	respRes = reqRes
 	for _, pipe := range l.Pipes {
 		respRes, err = pipe.Handle(ctx, respRes)
@@ -22,9 +31,16 @@ func (l Line) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes
 	return
 }
 
-// Handle calls l.Pipes one by one, save the copy of respRes, returns immediately when one Pipe.Handle returns error.
+// HandleVerbosely calls l.Pipes one by one, save the copy of respRes, returns immediately when one Pipe.Handle returns error.
+// See HandleTraced for a ExecutionTrace with the timing, the identity and the error of every step.
 func (l Line) HandleVerbosely(ctx context.Context, reqRes *HandleRes) (respReses []HandleRes, err error) {
 	respRes := reqRes
+	if l.Observer != nil {
+		var finish func(res *HandleRes, err error)
+		ctx, finish = l.Observer.StartLine(ctx, l, reqRes)
+		defer func() { finish(respRes, err) }()
+	}
+
 	respReses = make([]HandleRes, 0, len(l.Pipes))
 
 	for _, pipe := range l.Pipes {
@@ -44,48 +60,65 @@ func (l Line) HandleVerbosely(ctx context.Context, reqRes *HandleRes) (respReses
 	return respReses, nil
 }
 
-// NewLineByJSON parses the jsonConf and creates a new Line, returns the pointer of it.
-// The jsonConf must be a JSON array, if the item of the array is a object, parses it with struct PipeConf,
-// else parse it with []PipeConf.
-// A object item will be used to create a single Pipe, a array item will be used to create a parallel Pipe.
-// The given handlerBuilders will be used to find a HandlerBuilder with the HandlerBuilderName in PipeConf.
-// The given handlers will be used to find a Handler with the RefHandlerID in PipeConf.
-func NewLineByJSON(jsonConf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) (*Line, error) {
-	line := &Line{Pipes: []Pipe{}}
-
+// ValidateLineJSON validates the jsonConf like NewLineByJSON without returning on the first error,
+// returns all the errors as one error with an Unwrap() []error method, which supports errors.Is on every one of them.
+// Every error is prefixed with the line, the column and the JSON path of the bad node, e.g. line 3, column 5: $[1].pipes[0].
+// The Handlers are built by the handlerBuilders during the validation.
+func ValidateLineJSON(jsonConf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) error {
 	confs := make([]json.RawMessage, 0)
 	if err := json.Unmarshal([]byte(jsonConf), &confs); err != nil {
-		return nil, err
+		return fmt.Errorf("$: %w", err)
 	}
 
-	for _, conf := range confs {
-		// single pipe
-		if strings.HasPrefix(string(conf), "{") {
-			var pc PipeConf
-			if err := json.Unmarshal(conf, &pc); err != nil {
-				return nil, err
-			}
-
-			pipe, err := NewSinglePipe(pc, handlerBuilders, handlers)
-			if err != nil {
-				return nil, err
-			}
+	errs := joinedErrs{}
+	parser := confParser{
+		handlerBuilders: handlerBuilders,
+		handlers:        handlers,
+		opts:            newOptions(opts),
+		errs:            &errs,
+	}
+	if _, err := parser.parseLine("$", confs); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
 
-			line.Pipes = append(line.Pipes, *pipe)
-			continue
-		}
+	positions := jsonPositions(jsonConf)
+	for i, err := range errs {
+		errs[i] = positionedConfErr(positions, err)
+	}
+	return errs
+}
 
-		// parallel pipe
-		var pcs []PipeConf
-		if err := json.Unmarshal(conf, &pcs); err != nil {
-			return nil, err
-		}
-		pipe, err := NewParallelPipe(pcs, handlerBuilders, handlers)
-		if err != nil {
-			return nil, err
-		}
-		line.Pipes = append(line.Pipes, *pipe)
+// NewLineByJSON parses the jsonConf and creates a new Line, returns the pointer of it.
+// The jsonConf must be a JSON array, every item of it is a node:
+//  1. a object without "type" or with "type":"single" is parsed with struct PipeConf to create a single Pipe;
+//  2. a object with "type":"line" and "pipes" creates a line Pipe, every item of "pipes" is a node;
+//  3. a object with "type":"parallel" and "pipes" creates a parallel Pipe, every item of "pipes" is a object node,
+//     the optional "merge" is the name of a Merger, the optional "meta_merge" is a MetaMerge,
+//     the optional "mode" is a ParallelMode, the "quorum" is required by ParallelModeQuorum,
+//     the optional "max_concurrency" limits the number of the Pipes running at the same time;
+//  4. a array item of a line is a shorthand of a parallel Pipe, every item of it is a object node;
+//  5. a object with "type":"switch", "cases" and optional "default" creates a switch Pipe,
+//     every case contains a Condition "when" and a line "pipes", the "default" is a line;
+//  6. a object with "type":"map", "pipe" and optional "max_concurrency" creates a map Pipe,
+//     the "pipe" is a object node used to handle every item of the Data.
+//
+// The given handlerBuilders will be used to find a HandlerBuilder with the HandlerBuilderName in PipeConf.
+// The given handlers will be used to find a Handler with the RefHandlerID in PipeConf.
+// The opts can set the Mergers, the Limiter, the Middlewares and the Observers, see Option.
+// The returned error is prefixed with the JSON path of the bad node, e.g. $[1].pipes[0].
+func NewLineByJSON(jsonConf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error) {
+	confs := make([]json.RawMessage, 0)
+	if err := json.Unmarshal([]byte(jsonConf), &confs); err != nil {
+		return nil, fmt.Errorf("$: %w", err)
 	}
 
-	return line, nil
+	parser := confParser{
+		handlerBuilders: handlerBuilders,
+		handlers:        handlers,
+		opts:            newOptions(opts),
+	}
+	return parser.parseLine("$", confs)
 }
@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -239,3 +240,125 @@ func BenchmarkLine_Handle(b *testing.B) {
 		line.Handle(context.Background(), &HandleRes{Data: float64(2)})
 	}
 }
+
+func TestLine_Handle_NoLeak(t *testing.T) {
+	line, err := NewLineByJSON(testFailedJSONConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := Line{Pipes: []Pipe{
+		{
+			Type:    PipeTypeSingle,
+			Conf:    PipeConf{Desc: "nested", Timeout: 100, Required: true},
+			Handler: line,
+		},
+	}}
+
+	noLeak(t, func() {
+		if _, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)}); err == nil {
+			t.Error("err is nil")
+		}
+	})
+	noLeak(t, func() {
+		res, err := nested.Handle(context.Background(), &HandleRes{Data: float64(2)})
+		if !errors.Is(err, ErrHandleFailed) {
+			t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+		}
+		if res.Status != HandleStatusTimeout {
+			t.Errorf("status: want=%v, got=%v", HandleStatusTimeout, res.Status)
+		}
+	})
+}
+
+func TestValidateLineJSON(t *testing.T) {
+	jsonConf := `[
+    {"ref_handler_id": "by_square", "timeout": 0},
+    [
+        {"ref_handler_id": "not_found", "timeout": 20, "required": true},
+        {"handler_builder_name": "not_found", "timeout": 20, "required": true},
+        [{"ref_handler_id": "by_square", "timeout": 20, "required": true}]
+    ],
+    {
+        "type": "parallel",
+        "merge": "sum",
+        "mode": "quorum",
+        "quorum": 3,
+        "pipes": [
+            {"handler_builder_name": "delay", "handler_builder_conf": {"delay": "soon"}, "timeout": 20, "required": true}
+        ]
+    },
+    {
+        "type": "switch",
+        "cases": [{"when": {"path": "data", "op": "eq"}, "pipes": [{"type": "loop"}]}]
+    },
+    {"type": "map", "max_concurrency": -1, "pipe": {"ref_handler_id": "by_square", "timeout": 20, "middlewares": ["log"], "required": true}}
+]`
+
+	err := ValidateLineJSON(jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err == nil {
+		t.Fatal("err is nil")
+	}
+
+	multi, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("err: want a multi-error, got=%T", err)
+	}
+	msgs := make([]string, 0)
+	for _, e := range multi.Unwrap() {
+		msgs = append(msgs, e.Error())
+	}
+	want := []string{
+		"line 2, column 5: $[0]: timeout less than or equal to 0",
+		"line 2, column 5: $[0]: non-required pipe need default data",
+		"line 4, column 9: $[1][0]: not_found: ref handler not found",
+		"line 5, column 9: $[1][1]: not_found: handler builder not found",
+		"line 6, column 9: $[1][2]: parallel pipe conf contains a array, use a line pipe instead",
+		`line 14, column 13: $[2].pipes[0]: delay: build handler failed: delay: invalid builder conf field: invalid duration: "soon"`,
+		"line 8, column 5: $[2]: merger not found: sum",
+		"line 8, column 5: $[2]: quorum out of range: 3 of 1",
+		`line 19, column 28: $[3].cases[0].when: invalid path: "data" must start with $`,
+		"line 19, column 68: $[3].cases[0].pipes[0]: unknown pipe type: loop",
+		"line 21, column 5: $[4]: max concurrency less than 0",
+		"line 21, column 52: $[4].pipe: log: middleware not found",
+	}
+	if text, ok := diff(want, msgs); !ok {
+		t.Error("errs diff:\n", text)
+	}
+
+	for _, sentinel := range []error{
+		ErrPipeConfTimeoutLessThanOrEqualToZero,
+		ErrPipeConfNonRequiredNilDefaultData,
+		ErrRefHandlerNotFound,
+		ErrHandlerBuilderNotFound,
+		ErrPipeConfNestedArray,
+		ErrBuildHandlerFailed,
+		ErrInvalidDuration,
+		ErrMergerNotFound,
+		ErrPipeConfInvalidQuorum,
+		ErrInvalidPath,
+		ErrPipeConfUnknownType,
+		ErrPipeConfNegativeMaxConcurrency,
+		ErrMiddlewareNotFound,
+	} {
+		if !errors.Is(err, sentinel) {
+			t.Errorf("err: want %v", sentinel)
+		}
+	}
+
+	// NewLineByJSON still returns the first error only
+	if _, err := NewLineByJSON(jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, ErrPipeConfTimeoutLessThanOrEqualToZero) || errors.Is(err, ErrRefHandlerNotFound) {
+		t.Errorf("NewLineByJSON err: got=%v", err)
+	}
+}
+
+func TestValidateLineJSON_Valid(t *testing.T) {
+	for _, jsonConf := range []string{testJSONConf, testNestedJSONConf, testSwitchJSONConf} {
+		if err := ValidateLineJSON(jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := ValidateLineJSON(`{}`, exampleHandlerBuilderGetter, exampleHandlerGetter); err == nil {
+		t.Error("err is nil")
+	}
+}
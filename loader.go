@@ -0,0 +1,320 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+type ConfFormat string
+
+const (
+	ConfFormatJSON ConfFormat = "json"
+	ConfFormatYAML ConfFormat = "yaml"
+	ConfFormatTOML ConfFormat = "toml"
+)
+
+// confFormatsByExt maps the file extensions to the ConfFormat.
+var confFormatsByExt = map[string]ConfFormat{
+	".json": ConfFormatJSON,
+	".yaml": ConfFormatYAML,
+	".yml":  ConfFormatYAML,
+	".toml": ConfFormatTOML,
+}
+
+// NewLineByYAML parses the yamlConf and creates a new Line like NewLineByJSON,
+// the yamlConf must be a YAML sequence with the same grammar as the JSON conf.
+// The error of a bad node is prefixed with the line and the column of it, e.g. line 3, column 5: $[1].pipes[0],
+// the syntax error contains the line reported by the YAML parser.
+func NewLineByYAML(yamlConf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlConf), &doc); err != nil {
+		return nil, fmt.Errorf("$: %w", err)
+	}
+
+	var v interface{}
+	if err := doc.Decode(&v); err != nil {
+		return nil, fmt.Errorf("$: %w", err)
+	}
+	if _, ok := v.([]interface{}); !ok {
+		return nil, positionedConfErr(yamlPositions(&doc), fmt.Errorf("$: %w", ErrPipeConfNotArray))
+	}
+
+	jsonConf, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("$: %w", err)
+	}
+	line, err := NewLineByJSON(string(jsonConf), handlerBuilders, handlers, opts...)
+	if err != nil {
+		return nil, positionedConfErr(yamlPositions(&doc), err)
+	}
+	return line, nil
+}
+
+// NewLineByTOML parses the tomlConf and creates a new Line like NewLineByJSON,
+// the "pipes" of the tomlConf is used as the JSON conf, the JSON path of the errors starts with $.pipes.
+// The error of a bad node is prefixed with the line and the column of it, e.g. line 5, column 11: $.pipes[1].timeout,
+// so is the syntax error.
+func NewLineByTOML(tomlConf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error) {
+	var root map[string]interface{}
+	if err := toml.Unmarshal([]byte(tomlConf), &root); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			row, column := decodeErr.Position()
+			return nil, fmt.Errorf("line %d, column %d: $: %w", row, column, err)
+		}
+		return nil, fmt.Errorf("$: %w", err)
+	}
+
+	pipes, ok := root["pipes"].([]interface{})
+	if !ok {
+		if _, found := root["pipes"]; !found {
+			return nil, fmt.Errorf("$: %w", ErrPipeConfNotArray)
+		}
+		return nil, positionedConfErr(tomlPositions(tomlConf), fmt.Errorf("$.pipes: %w", ErrPipeConfNotArray))
+	}
+	jsonConf, err := json.Marshal(pipes)
+	if err != nil {
+		return nil, fmt.Errorf("$: %w", err)
+	}
+	line, err := NewLineByJSON(string(jsonConf), handlerBuilders, handlers, opts...)
+	if err != nil {
+		return nil, positionedConfErr(tomlPositions(tomlConf), rerootedConfErr{root: "$.pipes", err: err})
+	}
+	return line, nil
+}
+
+// NewLineByReader reads the conf in the format from the r and creates a new Line.
+func NewLineByReader(r io.Reader, format ConfFormat, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ConfFormatJSON:
+		return NewLineByJSON(string(b), handlerBuilders, handlers, opts...)
+	case ConfFormatYAML:
+		return NewLineByYAML(string(b), handlerBuilders, handlers, opts...)
+	case ConfFormatTOML:
+		return NewLineByTOML(string(b), handlerBuilders, handlers, opts...)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPipeConfUnknownFormat, format)
+}
+
+// NewLineByFile reads the conf from the file and creates a new Line,
+// the format is detected by the extension of the file: .json, .yaml, .yml or .toml.
+// The returned error is prefixed with the name of the file.
+func NewLineByFile(name string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	format, ok := confFormatsByExt[ext]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: %s", name, ErrPipeConfUnknownFormat, ext)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := NewLineByReader(f, format, handlerBuilders, handlers, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return line, nil
+}
+
+type confPosition struct {
+	line   int
+	column int
+}
+
+// yamlPositions returns the positions of the nodes of the doc keyed by their JSON path, e.g. $[1].pipes[0].
+func yamlPositions(doc *yaml.Node) map[string]confPosition {
+	positions := map[string]confPosition{}
+	var walk func(path string, node *yaml.Node)
+	walk = func(path string, node *yaml.Node) {
+		positions[path] = confPosition{line: node.Line, column: node.Column}
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) > 0 {
+				walk(path, node.Content[0])
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(fmt.Sprintf("%s[%d]", path, i), item)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(path+"."+node.Content[i].Value, node.Content[i+1])
+			}
+		}
+	}
+	walk("$", doc)
+	return positions
+}
+
+// tomlPositions returns the positions of the nodes of the valid tomlConf keyed by their JSON path,
+// e.g. $.pipes[1].pipes[0], the [[pipes]] of an array table are positioned at their headers.
+func tomlPositions(tomlConf string) map[string]confPosition {
+	positions := map[string]confPosition{}
+	arrayTableCounts := map[string]int{}
+	var parser unstable.Parser
+	parser.Reset([]byte(tomlConf))
+
+	position := func(node *unstable.Node) confPosition {
+		start := parser.Shape(node.Raw).Start
+		return confPosition{line: start.Line, column: start.Column}
+	}
+	record := func(path string, pos confPosition) {
+		if _, ok := positions[path]; !ok {
+			positions[path] = pos
+		}
+	}
+	// keyPath appends the parts of the dotted key to the path,
+	// a part naming an array table refers to its last table
+	keyPath := func(path string, key unstable.Iterator) string {
+		for key.Next() {
+			path += "." + string(key.Node().Data)
+			if n, ok := arrayTableCounts[path]; ok && !key.IsLast() {
+				path += fmt.Sprintf("[%d]", n-1)
+			}
+		}
+		return path
+	}
+
+	var walkValue func(path string, node *unstable.Node, pos confPosition)
+	walkValue = func(path string, node *unstable.Node, pos confPosition) {
+		if node.Raw.Length > 0 {
+			pos = position(node)
+		}
+		record(path, pos)
+		switch node.Kind {
+		case unstable.Array:
+			i := 0
+			for children := node.Children(); children.Next(); {
+				if child := children.Node(); child.Kind != unstable.Comment {
+					walkValue(fmt.Sprintf("%s[%d]", path, i), child, pos)
+					i++
+				}
+			}
+		case unstable.InlineTable:
+			for children := node.Children(); children.Next(); {
+				if child := children.Node(); child.Kind == unstable.KeyValue {
+					walkValue(keyPath(path, child.Key()), child.Value(), position(child))
+				}
+			}
+		}
+	}
+
+	table := "$"
+	record(table, confPosition{line: 1, column: 1})
+	for parser.NextExpression() {
+		expr := parser.Expression()
+		switch expr.Kind {
+		case unstable.KeyValue:
+			walkValue(keyPath(table, expr.Key()), expr.Value(), position(expr))
+		case unstable.Table:
+			table = keyPath("$", expr.Key())
+			record(table, position(expr.Child()))
+		case unstable.ArrayTable:
+			array := keyPath("$", expr.Key())
+			record(array, position(expr.Child()))
+			table = fmt.Sprintf("%s[%d]", array, arrayTableCounts[array])
+			arrayTableCounts[array]++
+			record(table, position(expr.Child()))
+		}
+	}
+	return positions
+}
+
+// jsonPositions returns the positions of the nodes of the valid jsonConf keyed by their JSON path, e.g. $[1].pipes[0].
+func jsonPositions(jsonConf string) map[string]confPosition {
+	positions := map[string]confPosition{}
+	decoder := json.NewDecoder(strings.NewReader(jsonConf))
+
+	// position returns the position of the next token, skips the whitespaces and the separators before it
+	position := func() confPosition {
+		offset := int(decoder.InputOffset())
+		for offset < len(jsonConf) && strings.IndexByte(" \t\r\n:,", jsonConf[offset]) >= 0 {
+			offset++
+		}
+		line := strings.Count(jsonConf[:offset], "\n") + 1
+		column := offset - strings.LastIndexByte(jsonConf[:offset], '\n')
+		return confPosition{line: line, column: column}
+	}
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		positions[path] = position()
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if err := walk(fmt.Sprintf("%s.%v", path, key)); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+		// the closing delim
+		_, err = decoder.Token()
+		return err
+	}
+	walk("$")
+	return positions
+}
+
+// rerootedConfErr replaces the root $ of the JSON path prefix of the err with the root.
+type rerootedConfErr struct {
+	root string
+	err  error
+}
+
+func (e rerootedConfErr) Error() string {
+	msg := e.err.Error()
+	if !strings.HasPrefix(msg, "$") {
+		return msg
+	}
+	return e.root + msg[1:]
+}
+
+func (e rerootedConfErr) Unwrap() error {
+	return e.err
+}
+
+// positionedConfErr prefixes the err with the position of the node in its JSON path prefix if it is found.
+func positionedConfErr(positions map[string]confPosition, err error) error {
+	path, _, ok := strings.Cut(err.Error(), ": ")
+	if !ok {
+		return err
+	}
+	pos, ok := positions[path]
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("line %d, column %d: %w", pos.line, pos.column, err)
+}
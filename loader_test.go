@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var testLoaderJSONConf = `
+[
+    {"desc": "square", "ref_handler_id": "by_square", "timeout": 20, "required": true},
+    [
+        {"ref_handler_id": "by_cubic", "timeout": 20, "required": true},
+        {
+            "type": "line",
+            "pipes": [
+                {"ref_handler_id": "failed_unknown", "timeout": 20, "default_data": 0.5},
+                {"type": "parallel", "merge": "first_non_nil", "pipes": [{"ref_handler_id": "by_square", "timeout": 20, "required": true}]}
+            ]
+        }
+    ]
+]
+`
+
+// toYAML and toTOML convert the jsonConf into the other formats.
+func toYAML(t *testing.T, jsonConf string) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(jsonConf), &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func toTOML(t *testing.T, jsonConf string) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(jsonConf), &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := toml.Marshal(map[string]interface{}{"pipes": v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestNewLineByYAMLAndTOML_RoundTrip(t *testing.T) {
+	jsonLine, err := NewLineByJSON(testLoaderJSONConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRes, err := jsonLine.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		caseName string
+		load     func() (*Line, error)
+	}{
+		{
+			caseName: "yaml",
+			load: func() (*Line, error) {
+				return NewLineByYAML(toYAML(t, testLoaderJSONConf), exampleHandlerBuilderGetter, exampleHandlerGetter)
+			},
+		},
+		{
+			caseName: "toml",
+			load: func() (*Line, error) {
+				return NewLineByTOML(toTOML(t, testLoaderJSONConf), exampleHandlerBuilderGetter, exampleHandlerGetter)
+			},
+		},
+		{
+			caseName: "reader",
+			load: func() (*Line, error) {
+				return NewLineByReader(strings.NewReader(toYAML(t, testLoaderJSONConf)), ConfFormatYAML, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			},
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			line, err := item.load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(jsonLine, line); !ok {
+				t.Error("line diff:\n", text)
+			}
+			res, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(wantRes, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestNewLineByFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"line.json": testLoaderJSONConf,
+		"line.yaml": toYAML(t, testLoaderJSONConf),
+		"line.yml":  toYAML(t, testLoaderJSONConf),
+		"line.toml": toTOML(t, testLoaderJSONConf),
+		"line.ini":  testLoaderJSONConf,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name := range files {
+		t.Run(name, func(t *testing.T) {
+			line, err := NewLineByFile(filepath.Join(dir, name), exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if name == "line.ini" {
+				if !errors.Is(err, ErrPipeConfUnknownFormat) {
+					t.Errorf("err: want=%v, got=%v", ErrPipeConfUnknownFormat, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(line.Pipes) != 2 {
+				t.Errorf("pipes len: want=%v, got=%v", 2, len(line.Pipes))
+			}
+		})
+	}
+
+	if _, err := NewLineByFile(filepath.Join(dir, "missing.yaml"), exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err: want=%v, got=%v", os.ErrNotExist, err)
+	}
+}
+
+func TestNewLineByYAMLAndTOML_Errors(t *testing.T) {
+	tt := []struct {
+		caseName  string
+		load      func(conf string, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter, opts ...Option) (*Line, error)
+		conf      string
+		err       error
+		errPrefix string
+	}{
+		{
+			caseName: "yaml syntax",
+			load:     NewLineByYAML,
+			conf: `
+- ref_handler_id: by_square
+  timeout: 20: 30
+`,
+			errPrefix: "$: yaml: line 3",
+		},
+		{
+			caseName:  "yaml not array",
+			load:      NewLineByYAML,
+			conf:      "ref_handler_id: by_square\n",
+			err:       ErrPipeConfNotArray,
+			errPrefix: "line 1, column 1: $: ",
+		},
+		{
+			caseName: "yaml bad node",
+			load:     NewLineByYAML,
+			conf: `
+- ref_handler_id: by_square
+  timeout: 20
+  required: true
+- - ref_handler_id: by_cubic
+    timeout: 20
+    required: true
+  -   type: unknown
+`,
+			err:       ErrPipeConfUnknownType,
+			errPrefix: "line 8, column 7: $[1][1]: ",
+		},
+		{
+			caseName: "yaml bad condition",
+			load:     NewLineByYAML,
+			conf: `
+- type: switch
+  cases:
+    - when: {path: $.data, op: eq, value: 1}
+      pipes: []
+    - when:
+        all:
+          - {path: data, op: eq, value: 1}
+      pipes: []
+`,
+			err:       ErrInvalidPath,
+			errPrefix: "line 8, column 13: $[0].cases[1].when.all[0]: ",
+		},
+		{
+			caseName: "toml syntax",
+			load:     NewLineByTOML,
+			conf: `
+[[pipes]]
+ref_handler_id = "by_square"
+timeout = = 20
+`,
+			errPrefix: "line 4, column 11: $: ",
+		},
+		{
+			caseName:  "toml no pipes",
+			load:      NewLineByTOML,
+			conf:      `desc = "line"`,
+			err:       ErrPipeConfNotArray,
+			errPrefix: "$: ",
+		},
+		{
+			caseName: "toml bad node",
+			load:     NewLineByTOML,
+			conf: `
+[[pipes]]
+type = "unknown"
+`,
+			err:       ErrPipeConfUnknownType,
+			errPrefix: "line 2, column 3: $.pipes[0]: ",
+		},
+		{
+			caseName:  "toml pipes not array",
+			load:      NewLineByTOML,
+			conf:      "\npipes = \"by_square\"\n",
+			err:       ErrPipeConfNotArray,
+			errPrefix: "line 2, column 9: $.pipes: ",
+		},
+		{
+			caseName: "toml bad nested node",
+			load:     NewLineByTOML,
+			conf: `
+[[pipes]]
+ref_handler_id = "by_square"
+timeout = 20
+required = true
+
+[[pipes]]
+type = "switch"
+
+[[pipes.cases]]
+when = {path = "$.data", op = "eq", value = 1}
+pipes = []
+
+[[pipes.cases]]
+when = {all = [
+  {path = "$.data", op = "eq", value = 1},
+  {path = "data", op = "eq", value = 1},
+]}
+pipes = []
+`,
+			err:       ErrInvalidPath,
+			errPrefix: "line 17, column 3: $.pipes[1].cases[1].when.all[1]: ",
+		},
+		{
+			caseName: "toml bad inline node",
+			load:     NewLineByTOML,
+			conf: `
+pipes = [
+  {ref_handler_id = "by_square", timeout = 20, required = true},
+  [
+    {ref_handler_id = "by_cubic", timeout = 20, required = true},
+    {type = "unknown"},
+  ],
+]
+`,
+			err:       ErrPipeConfUnknownType,
+			errPrefix: "line 6, column 5: $.pipes[1][1]: ",
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			_, err := item.load(item.conf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if err == nil {
+				t.Fatal("err is nil")
+			}
+			if item.err != nil && !errors.Is(err, item.err) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+			if !strings.HasPrefix(err.Error(), item.errPrefix) {
+				t.Errorf("err prefix: want=%q, got=%q", item.errPrefix, err.Error())
+			}
+		})
+	}
+}
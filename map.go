@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Map handles every item of the HandleRes.Data by the Pipe concurrently.
+type Map struct {
+	Pipe           Pipe `json:"pipe"`
+	MaxConcurrency int  `json:"max_concurrency"` // 0 means no limit
+}
+
+// Handle implements the Handler.
+// Handles every item of the given reqRes.Data with the m.Pipe concurrently, at most m.MaxConcurrency at the same time,
+// collects the response of them in order, return a list of HandleRes.Data, join the error into one,
+// returns non-nil error when the reqRes.Data is not a slice or any item returns a non-nil error.
+func (m Map) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, err error) {
+	var meta map[string]interface{}
+	var data interface{}
+	if reqRes != nil {
+		meta = reqRes.Meta
+		data = reqRes.Data
+	}
+
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		e := fmt.Errorf("%w: %w", ErrHandleFailed, ErrMapDataNotSlice)
+		return &HandleRes{
+			Status:  HandleStatusFailed,
+			Message: e.Error(),
+			Meta:    meta,
+			Data:    data,
+		}, e
+	}
+
+	var sem chan struct{}
+	if m.MaxConcurrency > 0 {
+		sem = make(chan struct{}, m.MaxConcurrency)
+	}
+
+	n := rv.Len()
+	reses := make([]interface{}, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if !acquire(ctx, sem) {
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(idx int, item interface{}) {
+			defer wg.Done()
+			defer release(sem)
+			defer recoverHandlerPanic(&errs[idx])
+
+			res, err := m.Pipe.Handle(ctx, &HandleRes{Meta: meta, Data: item})
+			errs[idx] = err
+			if res != nil {
+				reses[idx] = res.Data
+			}
+		}(i, rv.Index(i).Interface())
+	}
+	wg.Wait()
+
+	if err := joinIndexedErrs(errs); err != nil {
+		return &HandleRes{
+			Status: HandleStatusFailed,
+			Meta:   meta,
+			Data:   reses,
+		}, err
+	}
+
+	return &HandleRes{
+		Status: HandleStatusOK,
+		Meta:   meta,
+		Data:   reses,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMap_Handle(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		data     interface{}
+		res      HandleRes
+		hasErr   bool
+	}{
+		{
+			caseName: "all handled",
+			jsonConf: `[{"type":"map","max_concurrency":2,"pipe":{"ref_handler_id":"by_square","timeout":20,"required":true}}]`,
+			data:     []interface{}{float64(1), float64(2), float64(3)},
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Data:   []float64{1, 4, 9},
+			},
+		},
+		{
+			caseName: "use default data",
+			jsonConf: `[{"type":"map","pipe":{"ref_handler_id":"failed_unknown","timeout":20,"default_data":0}}]`,
+			data:     []float64{1, 2},
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Data:   []float64{0, 0},
+			},
+		},
+		{
+			caseName: "failed",
+			jsonConf: `[{"type":"map","pipe":{"ref_handler_id":"failed_unknown","timeout":20,"required":true}}]`,
+			data:     []interface{}{float64(1), float64(2)},
+			res: HandleRes{
+				Status: HandleStatusFailed,
+				Data:   []interface{}{nil, nil},
+			},
+			hasErr: true,
+		},
+		{
+			caseName: "data not slice",
+			jsonConf: `[{"type":"map","pipe":{"ref_handler_id":"by_square","timeout":20,"required":true}}]`,
+			data:     float64(1),
+			res: HandleRes{
+				Status:  HandleStatusFailed,
+				Message: ErrHandleFailed.Error() + ": " + ErrMapDataNotSlice.Error(),
+				Data:    1,
+			},
+			hasErr: true,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			line, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := line.Handle(context.Background(), &HandleRes{Data: item.data})
+			if item.hasErr {
+				if err == nil {
+					t.Error("err is nil")
+				} else {
+					t.Log(err)
+				}
+			} else if err != nil {
+				t.Error(err)
+			}
+
+			if text, ok := diff(item.res, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestMap_Handle_ErrFormat(t *testing.T) {
+	m := Map{
+		Pipe: Pipe{
+			Type:    PipeTypeSingle,
+			Conf:    PipeConf{Desc: "failed", Timeout: 20, Required: true},
+			Handler: failedUnknown,
+		},
+	}
+
+	_, err := m.Handle(context.Background(), &HandleRes{Data: []int{1}})
+	if !errors.Is(err, ErrHandleFailed) {
+		t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+	}
+	if !strings.Contains(err.Error(), "errs: 1:failed") {
+		t.Errorf("err: want indexed errs, got=%v", err)
+	}
+}
+
+func TestMap_Handle_MaxConcurrency(t *testing.T) {
+	handler, maxRunning := concurrencyRecorder(time.Millisecond * 10)
+	m := Map{
+		MaxConcurrency: 2,
+		Pipe: Pipe{
+			Type:    PipeTypeSingle,
+			Conf:    PipeConf{Timeout: 200, Required: true},
+			Handler: handler,
+		},
+	}
+
+	res, err := m.Handle(context.Background(), &HandleRes{Data: []int{1, 2, 3, 4, 5, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff([]int{1, 2, 3, 4, 5, 6}, res.Data); !ok {
+		t.Error("data diff:\n", text)
+	}
+	if *maxRunning != 2 {
+		t.Errorf("max running: want=%v, got=%v", 2, *maxRunning)
+	}
+}
+
+func TestMap_Handle_Canceled(t *testing.T) {
+	m := Map{
+		MaxConcurrency: 1,
+		Pipe: Pipe{
+			Type:    PipeTypeSingle,
+			Conf:    PipeConf{Timeout: 1000, Required: true},
+			Handler: delay1000,
+		},
+	}
+
+	noLeak(t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		if _, err := m.Handle(ctx, &HandleRes{Data: []int{1, 2, 3}}); !errors.Is(err, ErrHandleFailed) {
+			t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+		}
+	})
+}
+
+func TestNewLineByJSON_Map_Err(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		err      error
+	}{
+		{
+			caseName: "negative max concurrency",
+			jsonConf: `[{"type":"map","max_concurrency":-1,"pipe":{"ref_handler_id":"by_square","timeout":20,"required":true}}]`,
+			err:      ErrPipeConfNegativeMaxConcurrency,
+		},
+		{
+			caseName: "missing pipe",
+			jsonConf: `[{"type":"map"}]`,
+			err:      ErrPipeConfNotObject,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if _, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, item.err) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Names of the built-in Mergers.
+const (
+	MergerList        = "list"
+	MergerKeyed       = "keyed"
+	MergerDeepMerge   = "deep_merge"
+	MergerConcat      = "concat"
+	MergerFirstNonNil = "first_non_nil"
+)
+
+// Merger merges the responses of a Parallel into one HandleRes.Data,
+// the reses are in the same order with the pipes.
+type Merger interface {
+	Merge(pipes []Pipe, reses []*HandleRes) (interface{}, error)
+}
+
+// MergerFunc type is an adapter to allow the use of ordinary functions as mergers.
+// If f is a function with the appropriate signature, MergerFunc(f) is a Merger that calls f.
+type MergerFunc func(pipes []Pipe, reses []*HandleRes) (interface{}, error)
+
+// Merge calls f(pipes, reses).
+func (f MergerFunc) Merge(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	return f(pipes, reses)
+}
+
+type MergerGetter interface {
+	GetMergerOK(name string) (Merger, bool)
+}
+
+// MapMergerGetter wraps a map[string]Merger as a MergerGetter.
+type MapMergerGetter map[string]Merger
+
+func (m MapMergerGetter) GetMergerOK(name string) (Merger, bool) {
+	merger, ok := m[name]
+	return merger, ok
+}
+
+var builtinMergers MapMergerGetter = map[string]Merger{
+	MergerList:        MergerFunc(mergeList),
+	MergerKeyed:       MergerFunc(mergeKeyed),
+	MergerDeepMerge:   MergerFunc(mergeDeep),
+	MergerConcat:      MergerFunc(mergeConcat),
+	MergerFirstNonNil: MergerFunc(mergeFirstNonNil),
+}
+
+// mergeList returns the list of every Data.
+func mergeList(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	list := make([]interface{}, len(reses))
+	for i, res := range reses {
+		list[i] = res.Data
+	}
+	return list, nil
+}
+
+// mergeKeyed returns a map of every Data keyed by the Desc of its Pipe, or the 1-based index if the Desc is empty.
+func mergeKeyed(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	keyed := make(map[string]interface{}, len(reses))
+	for i, res := range reses {
+		key := pipes[i].Conf.Desc
+		if key == "" {
+			key = fmt.Sprint(i + 1)
+		}
+		if _, ok := keyed[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrMergeFailed, key)
+		}
+		keyed[key] = res.Data
+	}
+	return keyed, nil
+}
+
+// mergeDeep merges every map Data recursively, the latter wins when the values are not maps.
+func mergeDeep(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	merged := map[string]interface{}{}
+	for i, res := range reses {
+		if res.Data == nil {
+			continue
+		}
+		m, ok := res.Data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: data of %d is not a map", ErrMergeFailed, i+1)
+		}
+		deepMergeInto(merged, m)
+	}
+	return merged, nil
+}
+
+func deepMergeInto(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcOK := v.(map[string]interface{})
+		dstMap, dstOK := dst[k].(map[string]interface{})
+		if srcOK && dstOK {
+			deepMergeInto(dstMap, srcMap)
+			continue
+		}
+		if srcOK {
+			copied := map[string]interface{}{}
+			deepMergeInto(copied, srcMap)
+			v = copied
+		}
+		dst[k] = v
+	}
+}
+
+// mergeConcat concatenates every slice Data.
+func mergeConcat(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	list := make([]interface{}, 0)
+	for i, res := range reses {
+		if res.Data == nil {
+			continue
+		}
+		rv := reflect.ValueOf(res.Data)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("%w: data of %d is not a slice", ErrMergeFailed, i+1)
+		}
+		for j := 0; j < rv.Len(); j++ {
+			list = append(list, rv.Index(j).Interface())
+		}
+	}
+	return list, nil
+}
+
+// mergeFirstNonNil returns the first non-nil Data.
+func mergeFirstNonNil(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+	for _, res := range reses {
+		if res.Data != nil {
+			return res.Data, nil
+		}
+	}
+	return nil, nil
+}
+
+type MetaMerge string
+
+const (
+	MetaMergeNone      MetaMerge = "none"       // use the Meta of the request
+	MetaMergeFirstWins MetaMerge = "first_wins" // the former pipe wins when the values of a key conflict
+	MetaMergeLastWins  MetaMerge = "last_wins"  // the latter pipe wins when the values of a key conflict
+	MetaMergeError     MetaMerge = "error"      // fails when the values of a key conflict
+)
+
+// Validate validates the MetaMerge, empty means MetaMergeLastWins.
+func (mm MetaMerge) Validate() error {
+	switch mm {
+	case "", MetaMergeNone, MetaMergeFirstWins, MetaMergeLastWins, MetaMergeError:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPipeConfUnknownMetaMerge, mm)
+}
+
+// merge merges the Meta of the reses by the rule, then overrides the keys of the reqMeta with them,
+// only the values different from the ones in the reqMeta take part in, since the reses inherit the reqMeta.
+// The records of the MetaKeyPipeline are always merged key by key, even with MetaMergeNone.
+func (mm MetaMerge) merge(reqMeta map[string]interface{}, reses []*HandleRes) (map[string]interface{}, error) {
+	if mm == "" {
+		mm = MetaMergeLastWins
+	}
+	merged := map[string]interface{}{}
+	var records map[string]interface{}
+	for _, res := range reses {
+		for k, v := range res.Meta {
+			if k == MetaKeyPipeline {
+				if records == nil {
+					records = mergeRecords(nil, reqMeta)
+				}
+				records = mergeRecords(records, res.Meta)
+				continue
+			}
+			if mm == MetaMergeNone {
+				continue
+			}
+			if reqValue, ok := reqMeta[k]; ok && reflect.DeepEqual(reqValue, v) {
+				continue
+			}
+			old, ok := merged[k]
+			switch {
+			case !ok || mm == MetaMergeLastWins:
+				merged[k] = v
+			case mm == MetaMergeError && !reflect.DeepEqual(old, v):
+				return nil, fmt.Errorf("%w: key %q", ErrMetaMergeConflict, k)
+			}
+		}
+	}
+	if records != nil {
+		merged[MetaKeyPipeline] = records
+	}
+
+	if len(merged) == 0 {
+		return reqMeta, nil
+	}
+	meta := make(map[string]interface{}, len(reqMeta)+len(merged))
+	for k, v := range reqMeta {
+		meta[k] = v
+	}
+	for k, v := range merged {
+		meta[k] = v
+	}
+	return meta, nil
+}
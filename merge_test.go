@@ -0,0 +1,274 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuiltinMergers(t *testing.T) {
+	pipes := []Pipe{{Conf: PipeConf{Desc: "a"}}, {Conf: PipeConf{Desc: "b"}}, {}}
+
+	tt := []struct {
+		caseName string
+		merger   string
+		pipes    []Pipe
+		reses    []*HandleRes
+		data     interface{}
+		err      error
+	}{
+		{
+			caseName: "list",
+			merger:   MergerList,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: 1}, {Data: nil}, {Data: "3"}},
+			data:     []interface{}{1, nil, "3"},
+		},
+		{
+			caseName: "keyed",
+			merger:   MergerKeyed,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: 1}, {Data: nil}, {Data: "3"}},
+			data:     map[string]interface{}{"a": 1, "b": nil, "3": "3"},
+		},
+		{
+			caseName: "keyed duplicate key",
+			merger:   MergerKeyed,
+			pipes:    []Pipe{{Conf: PipeConf{Desc: "a"}}, {Conf: PipeConf{Desc: "a"}}},
+			reses:    []*HandleRes{{Data: 1}, {Data: 2}},
+			err:      ErrMergeFailed,
+		},
+		{
+			caseName: "deep merge",
+			merger:   MergerDeepMerge,
+			pipes:    pipes,
+			reses: []*HandleRes{
+				{Data: map[string]interface{}{"a": 1, "m": map[string]interface{}{"x": 1, "y": 1}}},
+				{Data: nil},
+				{Data: map[string]interface{}{"b": 2, "m": map[string]interface{}{"y": 2}}},
+			},
+			data: map[string]interface{}{"a": 1, "b": 2, "m": map[string]interface{}{"x": 1, "y": 2}},
+		},
+		{
+			caseName: "deep merge non-map",
+			merger:   MergerDeepMerge,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: 1}, {Data: nil}, {Data: nil}},
+			err:      ErrMergeFailed,
+		},
+		{
+			caseName: "concat",
+			merger:   MergerConcat,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: []int{1, 2}}, {Data: nil}, {Data: []interface{}{"3"}}},
+			data:     []interface{}{1, 2, "3"},
+		},
+		{
+			caseName: "concat non-slice",
+			merger:   MergerConcat,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: 1}, {Data: nil}, {Data: nil}},
+			err:      ErrMergeFailed,
+		},
+		{
+			caseName: "first non-nil",
+			merger:   MergerFirstNonNil,
+			pipes:    pipes,
+			reses:    []*HandleRes{{Data: nil}, {Data: 2}, {Data: 3}},
+			data:     2,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			data, err := builtinMergers[item.merger].Merge(item.pipes, item.reses)
+			if item.err != nil {
+				if !errors.Is(err, item.err) {
+					t.Errorf("err: want=%v, got=%v", item.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.data, data); !ok {
+				t.Error("data diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestMetaMerge_merge(t *testing.T) {
+	reqMeta := map[string]interface{}{"req": 1, "k": 0}
+	reses := []*HandleRes{
+		{Meta: map[string]interface{}{"k": 1, "a": 1}},
+		{Meta: map[string]interface{}{"k": 2, "b": 2}},
+	}
+
+	tt := []struct {
+		caseName string
+		mm       MetaMerge
+		meta     map[string]interface{}
+		err      error
+	}{
+		{
+			caseName: "default",
+			mm:       "",
+			meta:     map[string]interface{}{"req": 1, "k": 2, "a": 1, "b": 2},
+		},
+		{
+			caseName: "none",
+			mm:       MetaMergeNone,
+			meta:     reqMeta,
+		},
+		{
+			caseName: "first wins",
+			mm:       MetaMergeFirstWins,
+			meta:     map[string]interface{}{"req": 1, "k": 1, "a": 1, "b": 2},
+		},
+		{
+			caseName: "last wins",
+			mm:       MetaMergeLastWins,
+			meta:     map[string]interface{}{"req": 1, "k": 2, "a": 1, "b": 2},
+		},
+		{
+			caseName: "error",
+			mm:       MetaMergeError,
+			err:      ErrMetaMergeConflict,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			meta, err := item.mm.merge(reqMeta, reses)
+			if item.err != nil {
+				if !errors.Is(err, item.err) {
+					t.Errorf("err: want=%v, got=%v", item.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.meta, meta); !ok {
+				t.Error("meta diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestMetaMerge_merge_Inherited(t *testing.T) {
+	reqMeta := map[string]interface{}{"k": 0, MetaKeyPipeline: map[string]interface{}{"req": "ok"}}
+	reses := []*HandleRes{
+		{Meta: map[string]interface{}{"k": 1, MetaKeyPipeline: map[string]interface{}{"req": "ok", "a": "ok"}}},
+		{Meta: map[string]interface{}{"k": 0, MetaKeyPipeline: map[string]interface{}{"req": "ok", "b": "ok"}}},
+	}
+	records := map[string]interface{}{"req": "ok", "a": "ok", "b": "ok"}
+
+	meta, err := MetaMergeError.merge(reqMeta, reses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(map[string]interface{}{"k": 1, MetaKeyPipeline: records}, meta); !ok {
+		t.Error("meta diff:\n", text)
+	}
+
+	meta, err = MetaMergeNone.merge(reqMeta, reses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(map[string]interface{}{"k": 0, MetaKeyPipeline: records}, meta); !ok {
+		t.Error("meta diff:\n", text)
+	}
+}
+
+func TestParallel_Handle_Merge(t *testing.T) {
+	sum := MergerFunc(func(pipes []Pipe, reses []*HandleRes) (interface{}, error) {
+		total := float64(0)
+		for _, res := range reses {
+			total += res.Data.(float64)
+		}
+		return total, nil
+	})
+
+	tt := []struct {
+		caseName string
+		jsonConf string
+		res      HandleRes
+		err      error
+	}{
+		{
+			caseName: "keyed",
+			jsonConf: `[{"type":"parallel","merge":"keyed","pipes":[{"desc":"square","ref_handler_id":"by_square","timeout":20,"required":true},{"desc":"cubic","ref_handler_id":"by_cubic","timeout":20,"required":true}]}]`,
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Meta:   map[string]interface{}{"k": "v"},
+				Data:   map[string]interface{}{"square": 4, "cubic": 8},
+			},
+		},
+		{
+			caseName: "custom merger",
+			jsonConf: `[{"type":"parallel","merge":"sum","pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true},{"ref_handler_id":"by_cubic","timeout":20,"required":true}]}]`,
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Meta:   map[string]interface{}{"k": "v"},
+				Data:   12,
+			},
+		},
+		{
+			caseName: "merge failed",
+			jsonConf: `[{"type":"parallel","merge":"concat","pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true}]}]`,
+			err:      ErrMergeFailed,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			line, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter, WithMergers(MapMergerGetter{"sum": sum}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := line.Handle(context.Background(), &HandleRes{Meta: map[string]interface{}{"k": "v"}, Data: float64(2)})
+			if item.err != nil {
+				if !errors.Is(err, item.err) {
+					t.Errorf("err: want=%v, got=%v", item.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.res, res); !ok {
+				t.Error("res diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestNewLineByJSON_Merge_Err(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		err      error
+	}{
+		{
+			caseName: "merger not found",
+			jsonConf: `[{"type":"parallel","merge":"not_found","pipes":[]}]`,
+			err:      ErrMergerNotFound,
+		},
+		{
+			caseName: "unknown meta merge",
+			jsonConf: `[{"type":"parallel","meta_merge":"random","pipes":[]}]`,
+			err:      ErrPipeConfUnknownMetaMerge,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if _, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, item.err) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetaKeyPipeline is the reserved key of HandleRes.Meta where the single Pipes record their status and timing,
+// enabled by WithMetaRecording. The value is a map[string]interface{} keyed by the desc of the Pipe,
+// or the path if the desc is empty, e.g. {"square": {"status": "ok", "duration_ms": 3, "default_used": false}},
+// the status is "skipped" if the SkipIf of the Pipe is true.
+const MetaKeyPipeline = "_pipeline"
+
+type MetaPolicy string
+
+const (
+	MetaPolicyMerge   MetaPolicy = "merge"   // the Meta of the request with the keys of the handler's overriding
+	MetaPolicyReplace MetaPolicy = "replace" // the Meta of the handler, or the Meta of the request if the handler failed
+	MetaPolicyKeep    MetaPolicy = "keep"    // the Meta of the request, the handler's is dropped
+)
+
+// Validate validates the MetaPolicy, empty means MetaPolicyMerge.
+func (mp MetaPolicy) Validate() error {
+	switch mp {
+	case "", MetaPolicyMerge, MetaPolicyReplace, MetaPolicyKeep:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPipeConfUnknownMetaPolicy, mp)
+}
+
+// propagate returns the Meta of the response of a single Pipe by the policy,
+// the handlerMeta is nil if the handler failed, only the keys in the keys of it are propagated if the keys is not nil.
+// The returned Meta is nil if both of them are empty, it may be one of them, so it must not be modified.
+func (mp MetaPolicy) propagate(reqMeta, handlerMeta map[string]interface{}, failed bool, keys []string) map[string]interface{} {
+	if keys != nil {
+		handlerMeta = filterMeta(handlerMeta, keys)
+	}
+
+	switch mp {
+	case MetaPolicyKeep:
+		return reqMeta
+	case MetaPolicyReplace:
+		if failed {
+			return reqMeta
+		}
+		return handlerMeta
+	}
+
+	if len(handlerMeta) == 0 {
+		return reqMeta
+	}
+	if len(reqMeta) == 0 {
+		return handlerMeta
+	}
+	meta := make(map[string]interface{}, len(reqMeta)+len(handlerMeta))
+	for k, v := range reqMeta {
+		meta[k] = v
+	}
+	for k, v := range handlerMeta {
+		meta[k] = v
+	}
+	return meta
+}
+
+// filterMeta returns the values of the keys in the meta, nil if none of them exists.
+func filterMeta(meta map[string]interface{}, keys []string) map[string]interface{} {
+	var filtered map[string]interface{}
+	for _, k := range keys {
+		if v, ok := meta[k]; ok {
+			if filtered == nil {
+				filtered = make(map[string]interface{}, len(keys))
+			}
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// recordMeta records the status and the duration of the pipe into the MetaKeyPipeline of the res.Meta,
+// the res.Meta is replaced by a copy.
+func (pipe Pipe) recordMeta(res *HandleRes, startTime time.Time, defaultUsed, skipped bool) {
+	if res == nil {
+		return
+	}
+	key := pipe.Conf.Desc
+	if key == "" {
+		key = pipe.Path
+	}
+	status := res.Status.String()
+	if skipped {
+		status = "skipped"
+	}
+	records, _ := res.Meta[MetaKeyPipeline].(map[string]interface{})
+	res.Meta = metaWith(res.Meta, MetaKeyPipeline, metaWith(records, key, map[string]interface{}{
+		"status":       status,
+		"duration_ms":  time.Since(startTime).Milliseconds(),
+		"default_used": defaultUsed,
+	}))
+}
+
+// mergeRecords copies the records in the MetaKeyPipeline of the meta into the dst, creates the dst if it is nil.
+func mergeRecords(dst map[string]interface{}, meta map[string]interface{}) map[string]interface{} {
+	records, ok := meta[MetaKeyPipeline].(map[string]interface{})
+	if !ok {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]interface{}, len(records))
+	}
+	for k, v := range records {
+		dst[k] = v
+	}
+	return dst
+}
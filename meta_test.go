@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var withMeta = HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+	return &HandleRes{Meta: map[string]interface{}{"a": 1, "b": 2}, Data: reqRes.Data}, nil
+})
+
+func TestMetaPolicy_propagate(t *testing.T) {
+	reqMeta := map[string]interface{}{"req": 1, "a": 0}
+	handlerMeta := map[string]interface{}{"a": 1, "b": 2}
+
+	tt := []struct {
+		caseName    string
+		mp          MetaPolicy
+		handlerMeta map[string]interface{}
+		failed      bool
+		keys        []string
+		meta        map[string]interface{}
+	}{
+		{caseName: "default", handlerMeta: handlerMeta, meta: map[string]interface{}{"req": 1, "a": 1, "b": 2}},
+		{caseName: "default failed", failed: true, meta: reqMeta},
+		{caseName: "merge keys", mp: MetaPolicyMerge, handlerMeta: handlerMeta, keys: []string{"b"}, meta: map[string]interface{}{"req": 1, "a": 0, "b": 2}},
+		{caseName: "merge no keys", mp: MetaPolicyMerge, handlerMeta: handlerMeta, keys: []string{}, meta: reqMeta},
+		{caseName: "replace", mp: MetaPolicyReplace, handlerMeta: handlerMeta, meta: handlerMeta},
+		{caseName: "replace nil", mp: MetaPolicyReplace},
+		{caseName: "replace failed", mp: MetaPolicyReplace, failed: true, meta: reqMeta},
+		{caseName: "keep", mp: MetaPolicyKeep, handlerMeta: handlerMeta, meta: reqMeta},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			meta := item.mp.propagate(reqMeta, item.handlerMeta, item.failed, item.keys)
+			if text, ok := diff(item.meta, meta); !ok {
+				t.Error("meta diff:\n", text)
+			}
+		})
+	}
+
+	if meta := MetaPolicy("").propagate(nil, nil, false, nil); meta != nil {
+		t.Errorf("want nil, got=%v", meta)
+	}
+	if err := MetaPolicy("random").Validate(); !errors.Is(err, ErrPipeConfUnknownMetaPolicy) {
+		t.Errorf("err: want=%v, got=%v", ErrPipeConfUnknownMetaPolicy, err)
+	}
+}
+
+func TestLine_Handle_MetaRecording(t *testing.T) {
+	handlers := MapHandlerGetter{"with_meta": withMeta, "by_square": bySquare, "failed_unknown": failedUnknown}
+	jsonConf := `[
+		{"desc":"meta","ref_handler_id":"with_meta","timeout":20,"required":true,"meta_keys":["a"]},
+		[
+			{"desc":"square","ref_handler_id":"by_square","timeout":20,"required":true},
+			{"ref_handler_id":"failed_unknown","timeout":20,"default_data":0}
+		]
+	]`
+	line, err := NewLineByJSON(jsonConf, nil, handlers, WithMetaRecording())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{Meta: map[string]interface{}{"req": 1}, Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Meta["req"] != 1 || res.Meta["a"] != 1 || res.Meta["b"] != nil {
+		t.Errorf("meta: got=%v", res.Meta)
+	}
+
+	records, _ := res.Meta[MetaKeyPipeline].(map[string]interface{})
+	want := map[string]struct {
+		status      string
+		defaultUsed bool
+	}{
+		"meta":    {status: "ok"},
+		"square":  {status: "ok"},
+		"$[1][1]": {status: "failed", defaultUsed: true},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records: want %d, got=%v", len(want), records)
+	}
+	for key, w := range want {
+		record, _ := records[key].(map[string]interface{})
+		if record["status"] != w.status || record["default_used"] != w.defaultUsed {
+			t.Errorf("record %s: want=%v, got=%v", key, w, record)
+		}
+		if _, ok := record["duration_ms"].(int64); !ok {
+			t.Errorf("record %s: duration_ms missing, got=%v", key, record)
+		}
+	}
+}
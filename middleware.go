@@ -0,0 +1,44 @@
+package pipeline
+
+import "context"
+
+// Middleware wraps a Handler to add cross-cutting behavior, e.g. logging, auth checks and metrics.
+// Gets the PipeInfo of the calling Pipe by PipeInfoFromContext.
+type Middleware func(next Handler) Handler
+
+// Chain composes the mws into one Middleware, the first one is the outermost.
+func Chain(mws ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+type MiddlewareGetter interface {
+	GetMiddlewareOK(name string) (Middleware, bool)
+}
+
+// MapMiddlewareGetter wraps a map[string]Middleware as a MiddlewareGetter.
+type MapMiddlewareGetter map[string]Middleware
+
+func (m MapMiddlewareGetter) GetMiddlewareOK(name string) (Middleware, bool) {
+	mw, ok := m[name]
+	return mw, ok
+}
+
+// PipeInfo describes the single Pipe which is calling the Handler.
+type PipeInfo struct {
+	Type PipeType
+	Path string // position of the Pipe in the conf of its Line, e.g. $[1].pipes[0]
+	Conf PipeConf
+}
+
+type pipeInfoKey struct{}
+
+// PipeInfoFromContext returns the PipeInfo of the single Pipe calling the Handler with the ctx.
+func PipeInfoFromContext(ctx context.Context) (PipeInfo, bool) {
+	info, ok := ctx.Value(pipeInfoKey{}).(PipeInfo)
+	return info, ok
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordMiddleware records the name and the PipeInfo into the logs before calling the next Handler.
+func recordMiddleware(name string, mu *sync.Mutex, logs *[]string) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+			info, ok := PipeInfoFromContext(ctx)
+			if !ok {
+				return nil, errors.New("pipe info not found")
+			}
+			mu.Lock()
+			*logs = append(*logs, fmt.Sprintf("%s:%s:%s", name, info.Path, info.Conf.Desc))
+			mu.Unlock()
+			return next.Handle(ctx, reqRes)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var logs []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+				logs = append(logs, name)
+				return next.Handle(ctx, reqRes)
+			})
+		}
+	}
+
+	handler := Chain(mw("a"), mw("b"), mw("c"))(bySquare)
+	res, err := handler.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != float64(4) {
+		t.Errorf("data: want=%v, got=%v", 4, res.Data)
+	}
+	if strings.Join(logs, ",") != "a,b,c" {
+		t.Errorf("logs: want=%v, got=%v", "a,b,c", logs)
+	}
+}
+
+func TestNewLineByJSON_Middlewares(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		logs []string
+	)
+	conf := `[
+		{"desc":"first","ref_handler_id":"by_square","timeout":20,"required":true,"middlewares":["auth"]},
+		[{"desc":"second","ref_handler_id":"by_square","timeout":20,"required":true}]
+	]`
+
+	line, err := NewLineByJSON(conf, exampleHandlerBuilderGetter, exampleHandlerGetter,
+		WithMiddlewares(recordMiddleware("global", &mu, &logs)),
+		WithNamedMiddlewares(MapMiddlewareGetter{"auth": recordMiddleware("auth", &mu, &logs)}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff([]int{16}, res.Data); !ok {
+		t.Error("data diff:\n", text)
+	}
+
+	want := "global:$[0]:first,auth:$[0]:first,global:$[1][0]:second"
+	if got := strings.Join(logs, ","); got != want {
+		t.Errorf("logs: want=%v, got=%v", want, got)
+	}
+}
+
+func TestNewLineByJSON_Middlewares_NotFound(t *testing.T) {
+	conf := `[{"ref_handler_id":"by_square","timeout":20,"required":true,"middlewares":["not_found"]}]`
+	if _, err := NewLineByJSON(conf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, ErrMiddlewareNotFound) {
+		t.Errorf("err: want=%v, got=%v", ErrMiddlewareNotFound, err)
+	}
+}
@@ -0,0 +1,67 @@
+package pipeline
+
+import "context"
+
+// Observer observes the executions of the Lines and the Pipes, e.g. to emit the spans or to collect the metrics,
+// see the subpackages pipelineotel and pipelineprom.
+type Observer interface {
+	// StartLine is called when the line starts handling the reqRes,
+	// returns the ctx passed down to its Pipes and a func called with the result when the line returns.
+	StartLine(ctx context.Context, line Line, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error))
+	// StartPipe is called when the pipe starts handling the reqRes,
+	// returns the ctx passed down to the handler and a func called with the result when the pipe returns.
+	StartPipe(ctx context.Context, pipe Pipe, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error, defaultUsed bool))
+	// StartBranch is called when the idx-th pipe of a Parallel starts running, after waiting for the MaxConcurrency,
+	// returns the ctx passed down to the pipe and a func called with the result when the branch returns.
+	StartBranch(ctx context.Context, idx int, pipe Pipe) (context.Context, func(respRes *HandleRes, err error))
+}
+
+// multiObserver notifies the observers in order, and finishes them in reverse order.
+type multiObserver []Observer
+
+func (mo multiObserver) StartLine(ctx context.Context, line Line, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error)) {
+	finishes := make([]func(respRes *HandleRes, err error), len(mo))
+	for i, o := range mo {
+		ctx, finishes[i] = o.StartLine(ctx, line, reqRes)
+	}
+	return ctx, func(respRes *HandleRes, err error) {
+		for i := len(finishes) - 1; i >= 0; i-- {
+			finishes[i](respRes, err)
+		}
+	}
+}
+
+func (mo multiObserver) StartPipe(ctx context.Context, pipe Pipe, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error, defaultUsed bool)) {
+	finishes := make([]func(respRes *HandleRes, err error, defaultUsed bool), len(mo))
+	for i, o := range mo {
+		ctx, finishes[i] = o.StartPipe(ctx, pipe, reqRes)
+	}
+	return ctx, func(respRes *HandleRes, err error, defaultUsed bool) {
+		for i := len(finishes) - 1; i >= 0; i-- {
+			finishes[i](respRes, err, defaultUsed)
+		}
+	}
+}
+
+func (mo multiObserver) StartBranch(ctx context.Context, idx int, pipe Pipe) (context.Context, func(respRes *HandleRes, err error)) {
+	finishes := make([]func(respRes *HandleRes, err error), len(mo))
+	for i, o := range mo {
+		ctx, finishes[i] = o.StartBranch(ctx, idx, pipe)
+	}
+	return ctx, func(respRes *HandleRes, err error) {
+		for i := len(finishes) - 1; i >= 0; i-- {
+			finishes[i](respRes, err)
+		}
+	}
+}
+
+// newObserver combines the observers into one, returns nil if there is none.
+func newObserver(observers []Observer) Observer {
+	switch len(observers) {
+	case 0:
+		return nil
+	case 1:
+		return observers[0]
+	}
+	return multiObserver(observers)
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+type observerCtxKey struct{}
+
+type observerLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// recordingObserver records the events of the pipes by their paths into the log.
+type recordingObserver struct {
+	name string
+	log  *observerLog
+}
+
+func (o *recordingObserver) record(event string) {
+	o.log.mu.Lock()
+	defer o.log.mu.Unlock()
+	o.log.events = append(o.log.events, event)
+}
+
+func (o *recordingObserver) StartLine(ctx context.Context, line Line, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error)) {
+	o.record(fmt.Sprintf("%s start line %d", o.name, len(line.Pipes)))
+	return ctx, func(respRes *HandleRes, err error) {
+		o.record(fmt.Sprintf("%s end line %d %v", o.name, len(line.Pipes), err != nil))
+	}
+}
+
+func (o *recordingObserver) StartPipe(ctx context.Context, pipe Pipe, reqRes *HandleRes) (context.Context, func(respRes *HandleRes, err error, defaultUsed bool)) {
+	o.record(fmt.Sprintf("%s start %s", o.name, pipe.Path))
+	return context.WithValue(ctx, observerCtxKey{}, o.name), func(respRes *HandleRes, err error, defaultUsed bool) {
+		o.record(fmt.Sprintf("%s end %s %v %v %v", o.name, pipe.Path, respRes.Status, err != nil, defaultUsed))
+	}
+}
+
+func (o *recordingObserver) StartBranch(ctx context.Context, idx int, pipe Pipe) (context.Context, func(respRes *HandleRes, err error)) {
+	o.record(fmt.Sprintf("%s start branch %d", o.name, idx))
+	return ctx, func(respRes *HandleRes, err error) {
+		o.record(fmt.Sprintf("%s end branch %d", o.name, idx))
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	jsonConf := `[
+		{"ref_handler_id": "by_square", "timeout": 20, "required": true},
+		[
+			{"ref_handler_id": "by_square", "timeout": 20, "required": true},
+			{"ref_handler_id": "failed_unknown", "timeout": 20, "default_data": 0}
+		]
+	]`
+	observer := &recordingObserver{name: "o", log: &observerLog{}}
+	line, err := NewLineByJSON(jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter, WithObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"o start line 2",
+		"o start $[0]",
+		"o end $[0] ok false false",
+		"o start $[1]",
+		"o start branch 0",
+		"o start branch 1",
+		"o start $[1][0]",
+		"o start $[1][1]",
+		"o end $[1][0] ok false false",
+		"o end $[1][1] failed false true",
+		"o end branch 0",
+		"o end branch 1",
+		"o end $[1] ok false false",
+		"o end line 2 false",
+	}
+	// the branches run concurrently
+	got := observer.log.events
+	sort.Strings(want[4:12])
+	sort.Strings(got[4:12])
+	if text, ok := diff(want, got); !ok {
+		t.Error("events diff:\n", text)
+	}
+}
+
+func TestMultiObserver(t *testing.T) {
+	log := &observerLog{}
+	first := &recordingObserver{name: "first", log: log}
+	second := &recordingObserver{name: "second", log: log}
+	var handlerCtxValue interface{}
+	pipe := Pipe{
+		Type: PipeTypeSingle,
+		Conf: PipeConf{Timeout: 20, Required: true},
+		Path: "$[0]",
+		Handler: HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+			handlerCtxValue = ctx.Value(observerCtxKey{})
+			return reqRes, nil
+		}),
+		Observer: newObserver([]Observer{first, second}),
+	}
+	if _, err := pipe.Handle(context.Background(), &HandleRes{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first start $[0]", "second start $[0]", "second end $[0] ok false false", "first end $[0] ok false false"}
+	if text, ok := diff(want, log.events); !ok {
+		t.Error("events diff:\n", text)
+	}
+	if handlerCtxValue != "second" {
+		t.Errorf("handler ctx: want=%v, got=%v", "second", handlerCtxValue)
+	}
+	if newObserver(nil) != nil {
+		t.Error("observer of none is not nil")
+	}
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+// Option configures the Line created by NewLineByJSON.
+type Option func(*options)
+
+type options struct {
+	mergers   MergerGetter
+	limiter   *Limiter
+	observers []Observer
+
+	retryableErrs map[string]error // the built-in ones and the ones added by WithRetryableErrs
+
+	recordMeta bool
+
+	middlewares      []Middleware
+	namedMiddlewares MiddlewareGetter
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{retryableErrs: make(map[string]error, len(builtinRetryableErrs))}
+	for name, sentinel := range builtinRetryableErrs {
+		o.retryableErrs[name] = sentinel
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMergers sets the MergerGetter used to find a Merger with the "merge" of a parallel Pipe,
+// which is not a built-in one.
+func WithMergers(mergers MergerGetter) Option {
+	return func(o *options) {
+		o.mergers = mergers
+	}
+}
+
+// WithLimiter sets the Limiter for every single Pipe,
+// shares one Limiter among Lines to limit the in-flight handler calls of all of them,
+// a nested Line with the same Limiter runs within the slot of its outer Pipe.
+func WithLimiter(limiter *Limiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithMiddlewares appends the Middlewares wrapping the handler of every single Pipe,
+// they are outside of the ones named in PipeConf.Middlewares.
+func WithMiddlewares(mws ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+// WithNamedMiddlewares sets the MiddlewareGetter used to find a Middleware with the names in PipeConf.Middlewares.
+func WithNamedMiddlewares(middlewares MiddlewareGetter) Option {
+	return func(o *options) {
+		o.namedMiddlewares = middlewares
+	}
+}
+
+// WithObserver appends the Observers of every Pipe and Parallel, e.g. to emit the spans or to collect the metrics.
+func WithObserver(observers ...Observer) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, observers...)
+	}
+}
+
+// WithRetryableErrs adds the error sentinels can be referred by their names in RetryConf.RetryOn,
+// besides the built-in "handle_timeout" and "handle_failed".
+func WithRetryableErrs(errs map[string]error) Option {
+	return func(o *options) {
+		for name, sentinel := range errs {
+			o.retryableErrs[name] = sentinel
+		}
+	}
+}
+
+// WithMetaRecording makes every single Pipe record its status and timing into the MetaKeyPipeline of the Meta.
+func WithMetaRecording() Option {
+	return func(o *options) {
+		o.recordMeta = true
+	}
+}
@@ -4,11 +4,27 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"sync"
+)
+
+type ParallelMode string
+
+const (
+	ParallelModeAll      ParallelMode = "all"       // waits for all the Pipes
+	ParallelModeFailFast ParallelMode = "fail_fast" // cancels the others when any Pipe returns a non-nil error
+	ParallelModeRace     ParallelMode = "race"      // returns the first OK result and cancels the others
+	ParallelModeQuorum   ParallelMode = "quorum"    // returns when Quorum Pipes are OK and cancels the others
 )
 
 type Parallel struct {
-	Pipes []Pipe `json:"pipes"`
+	Pipes     []Pipe       `json:"pipes"`
+	Merger    Merger       `json:"-"`          // merges the Data of the Pipes, nil means a list of them
+	MetaMerge MetaMerge    `json:"meta_merge"` // merges the Meta of the Pipes, empty means MetaMergeLastWins
+	Mode      ParallelMode `json:"mode"`       // empty means ParallelModeAll
+	Quorum    int          `json:"quorum"`     // used by ParallelModeQuorum
+
+	MaxConcurrency int `json:"max_concurrency"` // 0 means no limit
+
+	Observer Observer `json:"-"` // observes the running Pipes, nil means no observing
 }
 
 func NewParallel(confs []PipeConf, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) (*Parallel, error) {
@@ -16,12 +32,38 @@ func NewParallel(confs []PipeConf, handlerBuilders HandlerBuilderGetter, handler
 	return &Parallel{Pipes: pipes}, err
 }
 
+// Validate validates the Mode, the Quorum and the MaxConcurrency of the parallel.
+// The Mode must be empty or one of the defined ParallelMode.
+// The Quorum must be in [1, len(parallel.Pipes)] for ParallelModeQuorum.
+// The MaxConcurrency must not be negative.
+func (parallel Parallel) Validate() error {
+	if parallel.MaxConcurrency < 0 {
+		return ErrPipeConfNegativeMaxConcurrency
+	}
+	switch parallel.Mode {
+	case "", ParallelModeAll, ParallelModeFailFast, ParallelModeRace:
+	case ParallelModeQuorum:
+		if parallel.Quorum < 1 || parallel.Quorum > len(parallel.Pipes) {
+			return fmt.Errorf("%w: %d of %d", ErrPipeConfInvalidQuorum, parallel.Quorum, len(parallel.Pipes))
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrPipeConfUnknownParallelMode, parallel.Mode)
+	}
+	return nil
+}
+
 // Handle handles the given reqRes parallelly by each Pipe fo parallel.Pipes,
-// collects the response of them, return a list of HandleRes, join the error into one,
-// returns non-nil error when any Pipe returns a non-nil error
+// collects the response of them, merges the Data by the parallel.Merger, a list of Data by default,
+// merges the Meta by the parallel.MetaMerge, join the error into one,
+// returns non-nil error when any Pipe returns a non-nil error or the merging failed.
+// With ParallelModeFailFast, returns immediately when any Pipe returns a non-nil error.
+// With ParallelModeRace, returns the Data of the first OK Pipe directly.
+// With ParallelModeQuorum, returns when parallel.Quorum Pipes are OK, or fails when it's impossible.
+// The unfinished Pipes are canceled when returned early.
+// At most parallel.MaxConcurrency Pipes run at the same time if it is positive.
 func (parallel Parallel) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, err error) {
-	var wg sync.WaitGroup
-	wg.Add(len(parallel.Pipes))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	respChan := make(chan struct {
 		idx int
@@ -29,47 +71,207 @@ func (parallel Parallel) Handle(ctx context.Context, reqRes *HandleRes) (respRes
 		err error
 	}, len(parallel.Pipes))
 
+	var sem chan struct{}
+	if parallel.MaxConcurrency > 0 {
+		sem = make(chan struct{}, parallel.MaxConcurrency)
+	}
+
 	for i, p := range parallel.Pipes {
 		go func(idx int, pipe Pipe) {
-			defer wg.Done()
-			res, err := pipe.Handle(ctx, reqRes)
-			respChan <- struct {
-				idx int
+			var (
 				res *HandleRes
 				err error
-			}{idx: idx, res: res, err: err}
+			)
+			defer func() {
+				respChan <- struct {
+					idx int
+					res *HandleRes
+					err error
+				}{idx: idx, res: res, err: err}
+			}()
+			defer recoverHandlerPanic(&err)
+
+			if !acquire(ctx, sem) {
+				err = ctx.Err()
+				return
+			}
+			defer release(sem)
+			branchCtx := ctx
+			if parallel.Observer != nil {
+				var finish func(res *HandleRes, err error)
+				branchCtx, finish = parallel.Observer.StartBranch(ctx, idx, pipe)
+				defer func() { finish(res, err) }()
+			}
+			res, err = pipe.Handle(branchCtx, reqRes)
 		}(i, p)
 	}
 
-	wg.Wait()
-	close(respChan)
+	quorum := 0
+	switch parallel.Mode {
+	case ParallelModeRace:
+		quorum = 1
+	case ParallelModeQuorum:
+		quorum = parallel.Quorum
+	}
 
-	reses := make([]interface{}, len(parallel.Pipes))
-	errs := make([]string, len(parallel.Pipes))
-	hasErr := false
-	for resp := range respChan {
-		errmsg := fmt.Sprint(resp.idx+1) + ":"
-		if resp.err != nil {
-			hasErr = true
-			errmsg += resp.err.Error()
+	reses := make([]*HandleRes, len(parallel.Pipes))
+	errs := make([]error, len(parallel.Pipes))
+	winner, okCount, notOKCount := -1, 0, 0
+	for range parallel.Pipes {
+		resp := <-respChan
+		errs[resp.idx] = resp.err
+		reses[resp.idx] = resp.res
+		if resp.res == nil {
+			reses[resp.idx] = &HandleRes{}
+		}
+
+		if resp.err == nil && reses[resp.idx].Status == HandleStatusOK {
+			okCount++
+			if winner < 0 {
+				winner = resp.idx
+			}
 		} else {
-			errmsg += "null"
+			notOKCount++
+		}
+
+		if parallel.Mode == ParallelModeFailFast && resp.err != nil {
+			break
+		}
+		if quorum > 0 && (okCount >= quorum || len(parallel.Pipes)-notOKCount < quorum) {
+			break
+		}
+	}
+	cancel()
+
+	// mark the unfinished ones
+	for i, res := range reses {
+		if res == nil {
+			reses[i] = &HandleRes{}
+			errs[i] = context.Canceled
 		}
-		errs[resp.idx] = errmsg
-		reses[resp.idx] = resp.res.Data
 	}
 
-	if hasErr {
+	var reqMeta map[string]interface{}
+	if reqRes != nil {
+		reqMeta = reqRes.Meta
+	}
+
+	if quorum > 0 {
+		return parallel.quorumRes(reqMeta, reses, errs, quorum, okCount, winner)
+	}
+
+	data, meta, mergeErr := parallel.merge(reqMeta, reses)
+	if err := joinIndexedErrs(errs); err != nil {
 		return &HandleRes{
 			Status: HandleStatusFailed,
-			Meta:   reqRes.Meta,
-			Data:   reses,
-		}, fmt.Errorf("%w: errs: %v", ErrHandleFailed, strings.Join(errs, ","))
+			Meta:   meta,
+			Data:   data,
+		}, err
+	}
+	if mergeErr != nil {
+		return &HandleRes{
+			Status:  HandleStatusFailed,
+			Message: mergeErr.Error(),
+			Meta:    meta,
+			Data:    data,
+		}, mergeErr
 	}
 
 	return &HandleRes{
 		Status: HandleStatusOK,
-		Meta:   reqRes.Meta,
-		Data:   reses,
+		Meta:   meta,
+		Data:   data,
 	}, nil
 }
+
+// quorumRes makes the response for ParallelModeRace and ParallelModeQuorum,
+// only the OK responses are merged, the Data of the winner is used directly for ParallelModeRace.
+func (parallel Parallel) quorumRes(reqMeta map[string]interface{}, reses []*HandleRes, errs []error, quorum, okCount, winner int) (*HandleRes, error) {
+	if okCount < quorum {
+		err := fmt.Errorf("%w: %d of %d ok, want %d", ErrParallelQuorumNotReached, okCount, len(reses), quorum)
+		if e := joinIndexedErrs(errs); e != nil {
+			err = fmt.Errorf("%w: %d of %d ok, want %d: %v", ErrParallelQuorumNotReached, okCount, len(reses), quorum, e)
+		}
+		return &HandleRes{
+			Status:  HandleStatusFailed,
+			Message: err.Error(),
+			Meta:    reqMeta,
+		}, err
+	}
+
+	okReses := make([]*HandleRes, len(reses))
+	for i, res := range reses {
+		okReses[i] = &HandleRes{}
+		if errs[i] == nil && res.Status == HandleStatusOK {
+			okReses[i] = res
+		}
+	}
+
+	var (
+		data interface{}
+		meta map[string]interface{}
+		err  error
+	)
+	if parallel.Mode == ParallelModeRace {
+		data = reses[winner].Data
+		if meta, err = parallel.MetaMerge.merge(reqMeta, okReses); err != nil {
+			meta = reqMeta
+		}
+	} else {
+		data, meta, err = parallel.merge(reqMeta, okReses)
+	}
+	if err != nil {
+		return &HandleRes{
+			Status:  HandleStatusFailed,
+			Message: err.Error(),
+			Meta:    meta,
+			Data:    data,
+		}, err
+	}
+	return &HandleRes{
+		Status: HandleStatusOK,
+		Meta:   meta,
+		Data:   data,
+	}, nil
+}
+
+// merge merges the Data of the reses by the parallel.Merger and the Meta of them by the parallel.MetaMerge,
+// uses the reqMeta if the merging of Meta failed.
+func (parallel Parallel) merge(reqMeta map[string]interface{}, reses []*HandleRes) (data interface{}, meta map[string]interface{}, err error) {
+	merger := parallel.Merger
+	if merger == nil {
+		merger = builtinMergers[MergerList]
+	}
+	data, err = merger.Merge(parallel.Pipes, reses)
+
+	meta, metaErr := parallel.MetaMerge.merge(reqMeta, reses)
+	if metaErr != nil {
+		meta = reqMeta
+		if err == nil {
+			err = metaErr
+		}
+	}
+	return data, meta, err
+}
+
+// joinIndexedErrs joins the errs into one error with their 1-based indexes, e.g. "1:null,2:handle failed",
+// returns nil if all of them are nil.
+func joinIndexedErrs(errs []error) error {
+	hasErr := false
+	errmsgs := make([]string, len(errs))
+	for i, err := range errs {
+		errmsg := fmt.Sprint(i+1) + ":"
+		if err != nil {
+			hasErr = true
+			errmsg += err.Error()
+		} else {
+			errmsg += "null"
+		}
+		errmsgs[i] = errmsg
+	}
+
+	if !hasErr {
+		return nil
+	}
+	return fmt.Errorf("%w: errs: %v", ErrHandleFailed, strings.Join(errmsgs, ","))
+}
@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -87,3 +88,205 @@ func TestParallel_Handle(t *testing.T) {
 		})
 	}
 }
+
+func TestParallel_Handle_NoLeak(t *testing.T) {
+	confs := []PipeConf{
+		{
+			RefHandlerID: "delay_1000",
+			Timeout:      20,
+			Required:     true,
+		},
+		{
+			RefHandlerID: "delay_1000",
+			Timeout:      20,
+			Required:     false,
+			DefaultData:  -1,
+		},
+		{
+			RefHandlerID: "by_square",
+			Timeout:      20,
+			Required:     true,
+		},
+	}
+	parallel, err := NewParallelPipe(confs, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noLeak(t, func() {
+		if _, err := parallel.Handle(context.Background(), &HandleRes{Data: float64(2)}); err == nil {
+			t.Error("err is nil")
+		}
+	})
+}
+
+// cancelObserver returns a Handler which blocks until its ctx is done,
+// the returned channel is closed when that happened.
+func cancelObserver() (Handler, chan struct{}) {
+	canceled := make(chan struct{})
+	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}), canceled
+}
+
+func TestParallel_Handle_Modes(t *testing.T) {
+	tt := []struct {
+		caseName string
+		mode     ParallelMode
+		quorum   int
+		handlers []Handler
+		res      HandleRes
+		err      error
+	}{
+		{
+			caseName: "fail fast",
+			mode:     ParallelModeFailFast,
+			handlers: []Handler{failedUnknown},
+			res:      HandleRes{Status: HandleStatusFailed, Data: []interface{}{nil, nil}},
+			err:      ErrHandleFailed,
+		},
+		{
+			caseName: "race",
+			mode:     ParallelModeRace,
+			handlers: []Handler{failedUnknown, byCubic},
+			res:      HandleRes{Status: HandleStatusOK, Data: 8},
+		},
+		{
+			caseName: "quorum",
+			mode:     ParallelModeQuorum,
+			quorum:   2,
+			handlers: []Handler{bySquare, failedUnknown, byCubic},
+			res:      HandleRes{Status: HandleStatusOK, Data: []interface{}{4, nil, 8, nil}},
+		},
+		{
+			caseName: "quorum not reached",
+			mode:     ParallelModeQuorum,
+			quorum:   3,
+			handlers: []Handler{bySquare, failedUnknown, failedUnknown},
+			res:      HandleRes{Status: HandleStatusFailed},
+			err:      ErrParallelQuorumNotReached,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			slow, canceled := cancelObserver()
+			handlers := append(item.handlers, slow)
+			parallel := Parallel{Mode: item.mode, Quorum: item.quorum}
+			for _, h := range handlers {
+				parallel.Pipes = append(parallel.Pipes, Pipe{
+					Type:    PipeTypeSingle,
+					Conf:    PipeConf{Timeout: 1000, Required: true},
+					Handler: h,
+				})
+			}
+			if err := parallel.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			noLeak(t, func() {
+				startTime := time.Now()
+				res, err := parallel.Handle(context.Background(), &HandleRes{Data: float64(2)})
+				if procDuration := time.Since(startTime); procDuration > time.Millisecond*100 {
+					t.Errorf("proc duration: want<=%v, got=%v", time.Millisecond*100, procDuration)
+				}
+				if item.err != nil {
+					if !errors.Is(err, item.err) {
+						t.Errorf("err: want=%v, got=%v", item.err, err)
+					}
+					res.Message = ""
+				} else if err != nil {
+					t.Error(err)
+				}
+				if text, ok := diff(item.res, res); !ok {
+					t.Error("res diff:\n", text)
+				}
+
+				select {
+				case <-canceled:
+				case <-time.After(time.Millisecond * 100):
+					t.Error("slow pipe is not canceled")
+				}
+			})
+		})
+	}
+}
+
+func TestParallel_Handle_Race_NoSuccess(t *testing.T) {
+	confs := []PipeConf{
+		{RefHandlerID: "failed_unknown", Timeout: 20, Required: true},
+		{RefHandlerID: "failed_unknown", Timeout: 20, Required: false, DefaultData: -1},
+	}
+	pipes, err := NewSinglePipes(confs, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parallel := Parallel{Pipes: pipes, Mode: ParallelModeRace}
+	res, err := parallel.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if !errors.Is(err, ErrParallelQuorumNotReached) {
+		t.Errorf("err: want=%v, got=%v", ErrParallelQuorumNotReached, err)
+	}
+	if res.Status != HandleStatusFailed {
+		t.Errorf("status: want=%v, got=%v", HandleStatusFailed, res.Status)
+	}
+}
+
+func TestNewLineByJSON_ParallelMode_Err(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		err      error
+	}{
+		{
+			caseName: "unknown mode",
+			jsonConf: `[{"type":"parallel","mode":"random","pipes":[]}]`,
+			err:      ErrPipeConfUnknownParallelMode,
+		},
+		{
+			caseName: "quorum out of range",
+			jsonConf: `[{"type":"parallel","mode":"quorum","quorum":2,"pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true}]}]`,
+			err:      ErrPipeConfInvalidQuorum,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if _, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, item.err) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+		})
+	}
+}
+
+func TestParallel_Handle_MaxConcurrency(t *testing.T) {
+	handler, maxRunning := concurrencyRecorder(time.Millisecond * 10)
+	conf := `[{"type":"parallel","max_concurrency":2,"pipes":[
+		{"ref_handler_id":"recorder","timeout":1000,"required":true},
+		{"ref_handler_id":"recorder","timeout":1000,"required":true},
+		{"ref_handler_id":"recorder","timeout":1000,"required":true},
+		{"ref_handler_id":"recorder","timeout":1000,"required":true}
+	]}]`
+	line, err := NewLineByJSON(conf, nil, MapHandlerGetter{"recorder": handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{Data: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff([]int{1, 1, 1, 1}, res.Data); !ok {
+		t.Error("data diff:\n", text)
+	}
+	if *maxRunning != 2 {
+		t.Errorf("max running: want=%v, got=%v", 2, *maxRunning)
+	}
+
+	conf = `[{"type":"parallel","max_concurrency":-1,"pipes":[]}]`
+	if _, err := NewLineByJSON(conf, nil, nil); !errors.Is(err, ErrPipeConfNegativeMaxConcurrency) {
+		t.Errorf("err: want=%v, got=%v", ErrPipeConfNegativeMaxConcurrency, err)
+	}
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a key of a map or an index of a slice.
+type pathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// dataPath is a parsed JSONPath-style path, e.g. $.user.tags[0].
+type dataPath []pathSegment
+
+// parsePath parses a JSONPath-style path, it must start with "$",
+// followed by any number of ".key" or "[index]".
+func parsePath(s string) (dataPath, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("%w: %q must start with $", ErrInvalidPath, s)
+	}
+
+	path := dataPath{}
+	rest := s[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("%w: %q has a empty key", ErrInvalidPath, s)
+			}
+			path = append(path, pathSegment{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: %q has a unclosed [", ErrInvalidPath, s)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("%w: %q has a invalid index %q", ErrInvalidPath, s, rest[1:end])
+			}
+			path = append(path, pathSegment{index: idx, isIdx: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: %q has a unexpected %q", ErrInvalidPath, s, rest[0])
+		}
+	}
+	return path, nil
+}
+
+// lookup finds the value in the v by the path, returns false if not found.
+func (path dataPath) lookup(v interface{}) (interface{}, bool) {
+	for _, seg := range path {
+		if v == nil {
+			return nil, false
+		}
+		rv := reflect.ValueOf(v)
+		switch {
+		case seg.isIdx && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array):
+			if seg.index >= rv.Len() {
+				return nil, false
+			}
+			v = rv.Index(seg.index).Interface()
+		case !seg.isIdx && rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+			item := rv.MapIndex(reflect.ValueOf(seg.key).Convert(rv.Type().Key()))
+			if !item.IsValid() {
+				return nil, false
+			}
+			v = item.Interface()
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// set returns a copy of the v with the value set at the path, the v is not modified,
+// only the maps and slices on the path are copied, the missing keys are created as maps.
+// Only map[string]interface{} and []interface{} can be set into, e.g. the values decoded from JSON.
+func (path dataPath) set(v interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	seg := path[0]
+	if seg.isIdx {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: [%d] of a %T", ErrDataPathNotSettable, seg.index, v)
+		}
+		if seg.index >= len(list) {
+			return nil, fmt.Errorf("%w: [%d] out of range", ErrDataPathNotSettable, seg.index)
+		}
+		item, err := path[1:].set(list[seg.index], value)
+		if err != nil {
+			return nil, err
+		}
+		copied := make([]interface{}, len(list))
+		copy(copied, list)
+		copied[seg.index] = item
+		return copied, nil
+	}
+
+	var m map[string]interface{}
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		m = v
+	default:
+		return nil, fmt.Errorf("%w: .%s of a %T", ErrDataPathNotSettable, seg.key, v)
+	}
+	item, err := path[1:].set(m[seg.key], value)
+	if err != nil {
+		return nil, err
+	}
+	copied := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		copied[k] = v
+	}
+	copied[seg.key] = item
+	return copied, nil
+}
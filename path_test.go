@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tt := []struct {
+		caseName string
+		path     string
+		hasErr   bool
+	}{
+		{caseName: "root", path: "$"},
+		{caseName: "keys and indexes", path: "$.data.tags[0][1].name"},
+		{caseName: "no $", path: "data.name", hasErr: true},
+		{caseName: "empty key", path: "$..name", hasErr: true},
+		{caseName: "unclosed [", path: "$.tags[0", hasErr: true},
+		{caseName: "negative index", path: "$.tags[-1]", hasErr: true},
+		{caseName: "unexpected char", path: "$name", hasErr: true},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			_, err := parsePath(item.path)
+			if item.hasErr {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Errorf("err: want=%v, got=%v", ErrInvalidPath, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDataPath_lookup(t *testing.T) {
+	data := map[string]interface{}{
+		"name": "foo",
+		"tags": []interface{}{"a", map[string]int{"b": 1}},
+	}
+
+	tt := []struct {
+		caseName string
+		path     string
+		value    interface{}
+		found    bool
+	}{
+		{caseName: "root", path: "$", value: data, found: true},
+		{caseName: "key", path: "$.name", value: "foo", found: true},
+		{caseName: "index", path: "$.tags[0]", value: "a", found: true},
+		{caseName: "typed map", path: "$.tags[1].b", value: 1, found: true},
+		{caseName: "key not found", path: "$.age"},
+		{caseName: "index out of range", path: "$.tags[2]"},
+		{caseName: "index on map", path: "$[0]"},
+		{caseName: "key on string", path: "$.name.first"},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			path, err := parsePath(item.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, found := path.lookup(data)
+			if found != item.found {
+				t.Fatalf("found: want=%v, got=%v", item.found, found)
+			}
+			if text, ok := diff(item.value, value); found && !ok {
+				t.Error("value diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestDataPath_set(t *testing.T) {
+	tt := []struct {
+		caseName string
+		data     interface{}
+		path     string
+		want     interface{}
+		hasErr   bool
+	}{
+		{caseName: "root", data: "foo", path: "$", want: 1},
+		{caseName: "nil data", path: "$.a.b", want: map[string]interface{}{"a": map[string]interface{}{"b": 1}}},
+		{
+			caseName: "keep other keys",
+			data:     map[string]interface{}{"a": "foo", "b": map[string]interface{}{"c": "bar"}},
+			path:     "$.b.d",
+			want:     map[string]interface{}{"a": "foo", "b": map[string]interface{}{"c": "bar", "d": 1}},
+		},
+		{
+			caseName: "index",
+			data:     map[string]interface{}{"list": []interface{}{"a", "b"}},
+			path:     "$.list[1]",
+			want:     map[string]interface{}{"list": []interface{}{"a", 1}},
+		},
+		{caseName: "index out of range", data: []interface{}{}, path: "$[0]", hasErr: true},
+		{caseName: "index on map", data: map[string]interface{}{}, path: "$[0]", hasErr: true},
+		{caseName: "key on string", data: "foo", path: "$.a", hasErr: true},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			path, err := parsePath(item.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, _ := json.Marshal(item.data)
+			got, err := path.set(item.data, 1)
+			if item.hasErr {
+				if !errors.Is(err, ErrDataPathNotSettable) {
+					t.Errorf("err: want=%v, got=%v", ErrDataPathNotSettable, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.want, got); !ok {
+				t.Error("value diff:\n", text)
+			}
+			if after, _ := json.Marshal(item.data); string(before) != string(after) {
+				t.Errorf("data modified: before=%s, after=%s", before, after)
+			}
+		})
+	}
+}
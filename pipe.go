@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -12,14 +13,32 @@ type PipeType string
 const (
 	PipeTypeSingle   = "single"
 	PipeTypeParallel = "parallel"
+	PipeTypeLine     = "line"
+	PipeTypeSwitch   = "switch"
+	PipeTypeMap      = "map"
 )
 
 // PipeConf used to create a new Pipe.
 type PipeConf struct {
 	Desc        string      `json:"desc"`
-	Timeout     int         `json:"timeout"` // in millisecond
+	Timeout     int         `json:"timeout"` // in millisecond, decoded from a duration string like "1.5s" too, see Millis
 	Required    bool        `json:"required"`
 	DefaultData interface{} `json:"default_data,omitempty"` // used when Pipe handling failed
+	Retry       *RetryConf  `json:"retry,omitempty"`        // retries the handler when it failed
+	Middlewares []string    `json:"middlewares,omitempty"`  // names of the Middlewares wrapping the handler
+
+	// InputPath is a JSONPath-style path of the Data passed to the handler, e.g. $.user, empty means the whole Data
+	InputPath string `json:"input_path,omitempty"`
+	// OutputPath is a JSONPath-style path of the Data to write the result into, e.g. $.profile,
+	// the other fields of the Data are kept, empty means replacing the whole Data with the result
+	OutputPath string `json:"output_path,omitempty"`
+
+	MetaPolicy MetaPolicy `json:"meta_policy,omitempty"` // how the Meta of the handler is propagated, empty means MetaPolicyMerge
+	MetaKeys   []string   `json:"meta_keys,omitempty"`   // keys of the Meta of the handler to propagate, nil means all
+
+	// SkipIf is a boolean expression evaluated against the request, the Pipe passes the request through if it is true,
+	// e.g. data.score > 60 && meta.tenant == "acme"
+	SkipIf string `json:"skip_if,omitempty"`
 
 	RefHandlerID string `json:"ref_handler_id"` // use a exiting Handler
 
@@ -28,23 +47,77 @@ type PipeConf struct {
 	HandlerBuilderConf map[string]interface{} `json:"handler_builder_conf"`
 }
 
+// UnmarshalJSON implements the json.Unmarshaler, the "timeout" is decoded as a Millis.
+func (pc *PipeConf) UnmarshalJSON(b []byte) error {
+	type pipeConf PipeConf
+	conf := struct {
+		*pipeConf
+		Timeout Millis `json:"timeout"`
+	}{pipeConf: (*pipeConf)(pc), Timeout: Millis(pc.Timeout)}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return err
+	}
+	pc.Timeout = int(conf.Timeout)
+	return nil
+}
+
 // Validate validates the PipeConf.
 // The Timeout must be positive.
 // The DefaultData must not be nil when Required is false.
+// The Retry must be valid if it is not nil.
+// The InputPath and the OutputPath must be valid paths if they are not empty.
+// The MetaPolicy must be valid.
+// The SkipIf must be a valid boolean expression if it is not empty.
 func (pc PipeConf) Validate() error {
+	if errs := pc.validateAll(builtinRetryableErrs); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// validateAll returns all the errors of the PipeConf, see Validate,
+// the RetryOn of the Retry must be keys of the retryableErrs.
+func (pc PipeConf) validateAll(retryableErrs map[string]error) []error {
+	var errs []error
 	if pc.Timeout <= 0 {
-		return ErrPipeConfTimeoutLessThanOrEqualToZero
+		errs = append(errs, ErrPipeConfTimeoutLessThanOrEqualToZero)
 	}
 	if !pc.Required && pc.DefaultData == nil {
-		return ErrPipeConfNonRequiredNilDefaultData
+		errs = append(errs, ErrPipeConfNonRequiredNilDefaultData)
 	}
-	return nil
+	if pc.Retry != nil {
+		if err := pc.Retry.validate(retryableErrs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := pc.MetaPolicy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if pc.SkipIf != "" {
+		if _, err := compileExpr(pc.SkipIf, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, path := range []string{pc.InputPath, pc.OutputPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := parsePath(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
 }
 
 type Pipe struct {
 	Type    PipeType `json:"type"`
 	Conf    PipeConf `json:"conf"`
 	Handler Handler  `json:"-"`
+	Limiter *Limiter `json:"-"`              // limits the in-flight calls of the Handler, nil means no limit
+	Path    string   `json:"path,omitempty"` // JSON path of the Pipe in the conf of its Line, e.g. $[1].pipes[0]
+
+	Observer   Observer `json:"-"` // observes the executions of the Pipe, nil means no observing
+	RecordMeta bool     `json:"-"` // records the status and timing of a single Pipe into the MetaKeyPipeline of the Meta
 }
 
 func NewSinglePipes(confs []PipeConf, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) ([]Pipe, error) {
@@ -61,33 +134,51 @@ func NewSinglePipes(confs []PipeConf, handlerBuilders HandlerBuilderGetter, hand
 
 func NewSinglePipe(conf PipeConf, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) (*Pipe, error) {
 	if err := conf.Validate(); err != nil {
+		if conf.Desc == "" {
+			return nil, err
+		}
 		return nil, fmt.Errorf("%s: %w", conf.Desc, err)
 	}
 
-	pipe := &Pipe{
-		Type: PipeTypeSingle,
-		Conf: conf,
+	handler, err := newSingleHandler(conf, handlerBuilders, handlers)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Retry != nil {
+		conf.Retry = conf.Retry.resolved(builtinRetryableErrs)
 	}
+	return &Pipe{
+		Type:    PipeTypeSingle,
+		Conf:    conf,
+		Handler: handler,
+	}, nil
+}
 
+// newSingleHandler finds the Handler with the conf.RefHandlerID, or builds one with the conf.HandlerBuilderName.
+func newSingleHandler(conf PipeConf, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) (Handler, error) {
 	if conf.RefHandlerID != "" {
 		if handler, ok := handlers.GetHandlerOK(conf.RefHandlerID); !ok {
 			return nil, fmt.Errorf("%s: %w", conf.RefHandlerID, ErrRefHandlerNotFound)
 		} else {
-			pipe.Handler = handler
-			return pipe, nil
+			return handler, nil
 		}
 	}
 
-	builder, ok := handlerBuilders.GetHandlerBuilderOK(conf.HandlerBuilderName)
+	builder, ok := getHandlerBuilder(handlerBuilders, conf.HandlerBuilderName)
 	if !ok {
 		return nil, fmt.Errorf("%s: %w", conf.HandlerBuilderName, ErrHandlerBuilderNotFound)
 	}
+	if err := validateBuilderConf(builder, conf.HandlerBuilderConf); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", conf.HandlerBuilderName, ErrBuildHandlerFailed, err)
+	}
 	handler, err := builder.Build(conf.HandlerBuilderConf)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w: %v", conf.HandlerBuilderName, ErrBuildHandlerFailed, err)
+		if errors.Is(err, ErrBuildHandlerFailed) {
+			return nil, fmt.Errorf("%s: %w", conf.HandlerBuilderName, err)
+		}
+		return nil, fmt.Errorf("%s: %w: %w", conf.HandlerBuilderName, ErrBuildHandlerFailed, err)
 	}
-	pipe.Handler = handler
-	return pipe, nil
+	return handler, nil
 }
 
 func NewParallelPipe(confs []PipeConf, handlerBuilders HandlerBuilderGetter, handlers HandlerGetter) (*Pipe, error) {
@@ -105,32 +196,80 @@ func NewParallelPipe(confs []PipeConf, handlerBuilders HandlerBuilderGetter, han
 }
 
 // Handle implements the Handler.
-// Handles the given reqRes, set timeout for single pipe, calls Handler.Handle directly for a parallel pipe.
+// Handles the given reqRes, set timeout for single pipe, calls Handler.Handle directly for other pipes.
+// The ctx passed to the internal handler is canceled when the timeout fires,
+// handlers should return as soon as ctx.Done() is closed,
+// the PipeInfo of the pipe can be got from the ctx by PipeInfoFromContext.
+// The execution is observed by the pipe.Observer, and recorded as a TraceStep when the ctx is from Line.HandleTraced.
+// A panic of the internal handler is recovered as a *HandlerPanicError, which is handled like other errors.
+// A single pipe passes the Meta and the Data of the reqRes through with HandleStatusOK without calling the handler
+// if its pipe.Conf.SkipIf is true,
+// a failed evaluation is handled like a handler error.
+// For a single pipe, the handler gets the Data at the pipe.Conf.InputPath,
+// its result (or the DefaultData) is written into the request Data at the pipe.Conf.OutputPath,
+// a missing input or a unsettable output is handled like a handler error.
+// The Meta of the response is made by the pipe.Conf.MetaPolicy, with the pipe.Conf.MetaKeys of the handler's.
 // Returns non-nil err when timeout or failed for a pipe which pipe.Conf.Required is true,
 // otherwise returns nil err and use the pipe.Conf.DefaultData.
 func (pipe Pipe) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, err error) {
-	if pipe.Type == PipeTypeParallel {
+	startTime := time.Now()
+	defaultUsed := false
+	if pipe.Observer != nil {
+		var finish func(res *HandleRes, err error, defaultUsed bool)
+		ctx, finish = pipe.Observer.StartPipe(ctx, pipe, reqRes)
+		defer func() { finish(respRes, err, defaultUsed) }()
+	}
+
+	if recorder := traceRecorderFromContext(ctx); recorder != nil {
+		var finish func(res *HandleRes, err error, defaultUsed bool)
+		ctx, finish = recorder.start(ctx, pipe, reqRes)
+		defer func() { finish(respRes, err, defaultUsed) }()
+	}
+
+	switch pipe.Type {
+	case PipeTypeParallel, PipeTypeLine, PipeTypeSwitch, PipeTypeMap:
 		return pipe.Handler.Handle(ctx, reqRes)
 	}
 
-	doneChan := make(chan struct {
-		res *HandleRes
-		err error
-	})
-	go func() {
-		res, e := pipe.Handler.Handle(ctx, reqRes)
-		doneChan <- struct {
-			res *HandleRes
-			err error
-		}{res: res, err: e}
-	}()
+	ctx = context.WithValue(ctx, pipeInfoKey{}, PipeInfo{Type: pipe.Type, Path: pipe.Path, Conf: pipe.Conf})
+	skipped := false
+	if pipe.RecordMeta {
+		defer func() { pipe.recordMeta(respRes, startTime, defaultUsed, skipped) }()
+	}
 
-	select {
-	case resp := <-doneChan:
-		err = resp.err
-		respRes = resp.res
-	case <-time.After(time.Millisecond * time.Duration(pipe.Conf.Timeout)):
-		err = MakeErrHandleTimeout(pipe.Conf.Desc, pipe.Conf.Timeout)
+	var reqMeta map[string]interface{}
+	if reqRes != nil {
+		reqMeta = reqRes.Meta
+	}
+
+	attempts := 1
+	skipped, err = pipe.skip(reqRes)
+	if err == nil && skipped {
+		res := &HandleRes{Status: HandleStatusOK}
+		if reqRes != nil {
+			res.Meta, res.Data = reqRes.Meta, reqRes.Data
+		}
+		return res, nil
+	}
+
+	var handlerReqRes *HandleRes
+	if err == nil {
+		handlerReqRes, err = pipe.mapInput(reqRes)
+	}
+	if err == nil {
+		if pipe.Conf.Retry == nil {
+			respRes, err = pipe.handleOnce(ctx, handlerReqRes, Millis(pipe.Conf.Timeout))
+		} else {
+			respRes, attempts, err = pipe.handleWithRetry(ctx, handlerReqRes)
+		}
+	}
+	if err == nil && pipe.Conf.OutputPath != "" {
+		res := &HandleRes{}
+		if respRes != nil {
+			*res = *respRes
+		}
+		res.Data, err = pipe.mapOutput(reqRes, res.Data)
+		respRes = res
 	}
 
 	// assign status
@@ -144,27 +283,150 @@ func (pipe Pipe) Handle(ctx context.Context, reqRes *HandleRes) (respRes *Handle
 
 	// fatal when required and non-nil err
 	if pipe.Conf.Required && err != nil {
-		e := fmt.Errorf("%s: %w: %v", pipe.Conf.Desc, ErrHandleFailed, err)
-		return &HandleRes{
+		e := fmt.Errorf("%s: %w: %w", pipe.Conf.Desc, ErrHandleFailed, err)
+		res := &HandleRes{
 			Status:  status,
 			Message: e.Error(),
-		}, e
+			Meta:    pipe.Conf.MetaPolicy.propagate(reqMeta, nil, true, pipe.Conf.MetaKeys),
+		}
+		if pipe.Conf.Retry != nil {
+			res.Meta = metaWith(res.Meta, MetaKeyRetryAttempts, attempts)
+		}
+		return res, e
 	}
 
 	// use default value when non-required and non-nil err
 	if !pipe.Conf.Required && err != nil {
-		return &HandleRes{
+		defaultUsed = true
+		res := &HandleRes{
 			Status:  status,
 			Message: err.Error(),
-			Meta:    reqRes.Meta,
-			Data:    pipe.Conf.DefaultData,
-		}, nil
+			Meta:    pipe.Conf.MetaPolicy.propagate(reqMeta, nil, true, pipe.Conf.MetaKeys),
+		}
+		data, mapErr := pipe.mapOutput(reqRes, pipe.Conf.DefaultData)
+		if mapErr != nil {
+			// keep the document of the request instead of discarding it
+			data = nil
+			if reqRes != nil {
+				data = reqRes.Data
+			}
+			res.Message = fmt.Sprintf("%s; default data: %v", res.Message, mapErr)
+		}
+		res.Data = data
+		if pipe.Conf.Retry != nil {
+			res.Meta = metaWith(res.Meta, MetaKeyRetryAttempts, attempts)
+		}
+		return res, nil
 	}
 
-	// ok
-	if respRes == nil {
-		respRes = &HandleRes{}
+	// ok, copy the respRes to avoid modifying the one owned by the handler, e.g. the reqRes
+	res := &HandleRes{}
+	if respRes != nil {
+		*res = *respRes
 	}
+	respRes = res
 	respRes.Status = status
+	respRes.Meta = pipe.Conf.MetaPolicy.propagate(reqMeta, respRes.Meta, false, pipe.Conf.MetaKeys)
+	if pipe.Conf.Retry != nil {
+		respRes.Meta = metaWith(respRes.Meta, MetaKeyRetryAttempts, attempts)
+	}
 	return respRes, nil
 }
+
+// mapInput returns a copy of the reqRes with the Data at the pipe.Conf.InputPath,
+// returns the reqRes itself if the InputPath is empty.
+func (pipe Pipe) mapInput(reqRes *HandleRes) (*HandleRes, error) {
+	if pipe.Conf.InputPath == "" {
+		return reqRes, nil
+	}
+	path, err := parsePath(pipe.Conf.InputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &HandleRes{}
+	if reqRes != nil {
+		*res = *reqRes
+	}
+	data, found := path.lookup(res.Data)
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrDataPathNotFound, pipe.Conf.InputPath)
+	}
+	res.Data = data
+	return res, nil
+}
+
+// mapOutput returns a copy of the Data of the reqRes with the data written at the pipe.Conf.OutputPath,
+// returns the data itself if the OutputPath is empty.
+func (pipe Pipe) mapOutput(reqRes *HandleRes, data interface{}) (interface{}, error) {
+	if pipe.Conf.OutputPath == "" {
+		return data, nil
+	}
+	path, err := parsePath(pipe.Conf.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqData interface{}
+	if reqRes != nil {
+		reqData = reqRes.Data
+	}
+	output, err := path.set(reqData, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pipe.Conf.OutputPath, err)
+	}
+	return output, nil
+}
+
+// handleOnce calls pipe.Handler.Handle with a ctx which will be canceled after timeout milliseconds,
+// the waiting for the pipe.Limiter is limited by the timeout too,
+// a slot of the pipe.Limiter already held by a caller of the ctx is reused.
+func (pipe Pipe) handleOnce(ctx context.Context, reqRes *HandleRes, timeout Millis) (*HandleRes, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout.Duration())
+	defer cancel()
+
+	acquired := pipe.Limiter != nil && !pipe.Limiter.held(ctx)
+	if acquired {
+		if err := pipe.Limiter.Acquire(ctx); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, MakeErrHandleTimeout(pipe.Conf.Desc, int(timeout))
+			}
+			return nil, err
+		}
+		ctx = pipe.Limiter.markHeld(ctx)
+	}
+
+	// buffered, so the handler goroutine never blocks on sending after a timeout
+	doneChan := make(chan struct {
+		res *HandleRes
+		err error
+	}, 1)
+	go func() {
+		var (
+			res *HandleRes
+			e   error
+		)
+		defer func() {
+			doneChan <- struct {
+				res *HandleRes
+				err error
+			}{res: res, err: e}
+		}()
+		defer recoverHandlerPanic(&e)
+		if acquired {
+			// released after the Handler returns, so the slot is held until the call really ends
+			defer pipe.Limiter.Release()
+		}
+		res, e = pipe.Handler.Handle(ctx, reqRes)
+	}()
+
+	select {
+	case resp := <-doneChan:
+		return resp.res, resp.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, MakeErrHandleTimeout(pipe.Conf.Desc, int(timeout))
+		}
+		return nil, ctx.Err()
+	}
+}
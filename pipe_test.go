@@ -2,9 +2,12 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPipeConf_Validate(t *testing.T) {
@@ -328,3 +331,184 @@ func TestSinglePipe_Handle_Nil_Req(t *testing.T) {
 		t.Errorf("want nil error, got: %v", err)
 	}
 }
+
+func TestSinglePipe_Handle_Timeout_Cancel(t *testing.T) {
+	canceled := make(chan struct{})
+	pipe := Pipe{
+		Type: PipeTypeSingle,
+		Conf: PipeConf{Desc: "slow", Timeout: 20, Required: true},
+		Handler: HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}),
+	}
+
+	noLeak(t, func() {
+		res, err := pipe.Handle(context.Background(), &HandleRes{})
+		if !errors.Is(err, ErrHandleFailed) {
+			t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+		}
+		if res.Status != HandleStatusTimeout {
+			t.Errorf("status: want=%v, got=%v", HandleStatusTimeout, res.Status)
+		}
+		select {
+		case <-canceled:
+		case <-time.After(time.Millisecond * 100):
+			t.Error("handler ctx is not canceled")
+		}
+	})
+}
+
+func TestSinglePipe_Handle_Parent_Canceled(t *testing.T) {
+	pipe, err := NewSinglePipe(PipeConf{
+		Timeout:      1000,
+		Required:     true,
+		RefHandlerID: "delay_1000",
+	}, nil, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noLeak(t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res, err := pipe.Handle(ctx, &HandleRes{})
+		if !errors.Is(err, ErrHandleFailed) {
+			t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+		}
+		if res.Status != HandleStatusFailed {
+			t.Errorf("status: want=%v, got=%v", HandleStatusFailed, res.Status)
+		}
+	})
+}
+
+func TestSinglePipe_Handle_SharedRespRes(t *testing.T) {
+	shared := &HandleRes{Meta: map[string]interface{}{"handler": true}, Data: float64(1)}
+	handler := HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		return shared, nil
+	})
+	pipe := Pipe{Type: PipeTypeSingle, Conf: PipeConf{Timeout: 20, Required: true}, Handler: handler}
+	parallel := Parallel{Pipes: []Pipe{pipe, pipe, pipe}}
+
+	for i := 0; i < 10; i++ {
+		reqRes := &HandleRes{Meta: map[string]interface{}{"req": i}}
+		res, err := parallel.Handle(context.Background(), reqRes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != HandleStatusOK {
+			t.Errorf("status: want=%v, got=%v", HandleStatusOK, res.Status)
+		}
+	}
+	if text, ok := diff(HandleRes{Meta: map[string]interface{}{"handler": true}, Data: 1}, shared); !ok {
+		t.Error("shared respRes modified:\n", text)
+	}
+}
+
+func TestSinglePipe_Handle_DataPath(t *testing.T) {
+	reqData := map[string]interface{}{"n": float64(2), "user": "foo"}
+	tt := []struct {
+		caseName string
+		pc       PipeConf
+		res      HandleRes
+		hasErr   bool
+	}{
+		{
+			caseName: "input and output",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", InputPath: "$.n", OutputPath: "$.result.square"},
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Data:   map[string]interface{}{"n": 2, "user": "foo", "result": map[string]interface{}{"square": 4}},
+			},
+		},
+		{
+			caseName: "output only",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "delay_10", OutputPath: "$.copy"},
+			res: HandleRes{
+				Status: HandleStatusOK,
+				Data:   map[string]interface{}{"n": 2, "user": "foo", "copy": reqData},
+			},
+		},
+		{
+			caseName: "default data at output",
+			pc:       PipeConf{Timeout: 20, DefaultData: -1, RefHandlerID: "failed_unknown", OutputPath: "$.square"},
+			res: HandleRes{
+				Status:  HandleStatusFailed,
+				Message: errUnknown.Error(),
+				Data:    map[string]interface{}{"n": 2, "user": "foo", "square": -1},
+			},
+		},
+		{
+			caseName: "default data not settable",
+			pc:       PipeConf{Timeout: 20, DefaultData: -1, RefHandlerID: "failed_unknown", OutputPath: "$.user.square"},
+			res: HandleRes{
+				Status:  HandleStatusFailed,
+				Message: errUnknown.Error() + "; default data: $.user.square: " + ErrDataPathNotSettable.Error() + ": .square of a string",
+				Data:    reqData,
+			},
+		},
+		{
+			caseName: "input not found",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", InputPath: "$.m"},
+			hasErr:   true,
+		},
+		{
+			caseName: "output not settable",
+			pc:       PipeConf{Timeout: 20, Required: true, RefHandlerID: "by_square", InputPath: "$.n", OutputPath: "$.user.square"},
+			hasErr:   true,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			pipe, err := NewSinglePipe(item.pc, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			respRes, err := pipe.Handle(context.Background(), &HandleRes{Data: reqData})
+			if item.hasErr {
+				if !errors.Is(err, ErrHandleFailed) {
+					t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.res, respRes); !ok {
+				t.Error("res diff:\n", text)
+			}
+			if _, ok := reqData["result"]; ok {
+				t.Error("reqRes.Data modified")
+			}
+		})
+	}
+}
+
+func TestSinglePipe_Handle_DefaultDataNotSettable(t *testing.T) {
+	pc := PipeConf{Timeout: 20, DefaultData: 0, RefHandlerID: "failed_unknown", OutputPath: "$.x"}
+	pipe, err := NewSinglePipe(pc, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := pipe.Handle(context.Background(), &HandleRes{Data: "scalar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data != "scalar" {
+		t.Errorf("data: want=%v, got=%v", "scalar", res.Data)
+	}
+	if !strings.Contains(res.Message, ErrDataPathNotSettable.Error()) {
+		t.Errorf("message: want containing %q, got=%q", ErrDataPathNotSettable, res.Message)
+	}
+}
+
+func TestPipeConf_Validate_DataPath(t *testing.T) {
+	pc := PipeConf{Timeout: 20, Required: true, InputPath: "n", OutputPath: "$."}
+	if errs := pc.validateAll(builtinRetryableErrs); len(errs) != 2 || !errors.Is(errs[0], ErrInvalidPath) || !errors.Is(errs[1], ErrInvalidPath) {
+		t.Errorf("errs: want 2 %v, got=%v", ErrInvalidPath, errs)
+	}
+}
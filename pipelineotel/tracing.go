@@ -0,0 +1,102 @@
+// Package pipelineotel emits the OpenTelemetry spans of the pipeline executions by a pipeline.Observer.
+package pipelineotel
+
+import (
+	"context"
+
+	pipeline "github.com/Focinfi/go-pipeline"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the OpenTelemetry Tracer used by the Observer.
+const TracerName = "github.com/Focinfi/go-pipeline"
+
+// Attribute keys of the spans.
+const (
+	AttrPipeDesc           = attribute.Key("pipeline.pipe.desc")
+	AttrPipeType           = attribute.Key("pipeline.pipe.type")
+	AttrPipePath           = attribute.Key("pipeline.pipe.path")
+	AttrPipeTimeout        = attribute.Key("pipeline.pipe.timeout")
+	AttrPipeRequired       = attribute.Key("pipeline.pipe.required")
+	AttrRefHandlerID       = attribute.Key("pipeline.pipe.ref_handler_id")
+	AttrHandlerBuilderName = attribute.Key("pipeline.pipe.handler_builder_name")
+	AttrStatus             = attribute.Key("pipeline.status")
+	AttrBranchIndex        = attribute.Key("pipeline.parallel.branch_index")
+	AttrPipesCount         = attribute.Key("pipeline.pipes.count")
+)
+
+// Observer emits a "Line.Handle" span for every Line, a "Pipe.Handle" span for every Pipe
+// and a "Parallel.branch" span for every parallel branch, the ctx passed to the handlers carries the span of its Pipe.
+// Use it by pipeline.WithObserver(pipelineotel.NewObserver(nil)).
+type Observer struct {
+	tracer trace.Tracer
+}
+
+// NewObserver creates a Observer with the Tracer of the tp,
+// the global TracerProvider set by otel.SetTracerProvider is used if the tp is nil.
+func NewObserver(tp trace.TracerProvider) *Observer {
+	if tp == nil {
+		return &Observer{tracer: otel.Tracer(TracerName)}
+	}
+	return &Observer{tracer: tp.Tracer(TracerName)}
+}
+
+// StartLine implements the pipeline.Observer.
+func (o *Observer) StartLine(ctx context.Context, line pipeline.Line, reqRes *pipeline.HandleRes) (context.Context, func(respRes *pipeline.HandleRes, err error)) {
+	ctx, span := o.tracer.Start(ctx, "Line.Handle", trace.WithAttributes(AttrPipesCount.Int(len(line.Pipes))))
+	return ctx, func(respRes *pipeline.HandleRes, err error) {
+		endSpan(span, respRes, err)
+	}
+}
+
+// StartPipe implements the pipeline.Observer.
+func (o *Observer) StartPipe(ctx context.Context, pipe pipeline.Pipe, reqRes *pipeline.HandleRes) (context.Context, func(respRes *pipeline.HandleRes, err error, defaultUsed bool)) {
+	ctx, span := o.tracer.Start(ctx, "Pipe.Handle", trace.WithAttributes(pipeAttributes(pipe)...))
+	return ctx, func(respRes *pipeline.HandleRes, err error, defaultUsed bool) {
+		endSpan(span, respRes, err)
+	}
+}
+
+// StartBranch implements the pipeline.Observer.
+func (o *Observer) StartBranch(ctx context.Context, idx int, pipe pipeline.Pipe) (context.Context, func(respRes *pipeline.HandleRes, err error)) {
+	ctx, span := o.tracer.Start(ctx, "Parallel.branch", trace.WithAttributes(AttrBranchIndex.Int(idx)))
+	return ctx, func(respRes *pipeline.HandleRes, err error) {
+		endSpan(span, respRes, err)
+	}
+}
+
+// pipeAttributes returns the attributes describing the pipe.
+func pipeAttributes(pipe pipeline.Pipe) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrPipeDesc.String(pipe.Conf.Desc),
+		AttrPipeType.String(string(pipe.Type)),
+		AttrPipePath.String(pipe.Path),
+	}
+	if pipe.Type == pipeline.PipeTypeSingle {
+		attrs = append(attrs,
+			AttrPipeTimeout.Int(pipe.Conf.Timeout),
+			AttrPipeRequired.Bool(pipe.Conf.Required),
+		)
+		if pipe.Conf.RefHandlerID != "" {
+			attrs = append(attrs, AttrRefHandlerID.String(pipe.Conf.RefHandlerID))
+		} else {
+			attrs = append(attrs, AttrHandlerBuilderName.String(pipe.Conf.HandlerBuilderName))
+		}
+	}
+	return attrs
+}
+
+// endSpan records the status of the res and the err into the span, then ends it.
+func endSpan(span trace.Span, res *pipeline.HandleRes, err error) {
+	if res != nil {
+		span.SetAttributes(AttrStatus.Int(int(res.Status)))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
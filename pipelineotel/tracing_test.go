@@ -0,0 +1,203 @@
+package pipelineotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pipeline "github.com/Focinfi/go-pipeline"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var testHandlers = pipeline.MapHandlerGetter{
+	"by_square": pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		n := reqRes.Data.(float64)
+		return &pipeline.HandleRes{Status: pipeline.HandleStatusOK, Data: n * n}, nil
+	}),
+	"delay_1000": pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		select {
+		case <-time.After(time.Second):
+			return reqRes, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}),
+}
+
+var testFailedJSONConf = `
+[
+    {"ref_handler_id": "by_square", "timeout": 20, "required": true},
+    [
+        {"ref_handler_id": "by_square", "timeout": 20, "required": true},
+        {"desc": "slow", "ref_handler_id": "delay_1000", "timeout": 200, "required": true}
+    ]
+]
+`
+
+// recordSpans sets a in-memory exporter for the global TracerProvider while running the f.
+func recordSpans(t *testing.T, f func()) tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	origin := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(origin)
+
+	f()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return exporter.GetSpans()
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestObserver(t *testing.T) {
+	line, err := pipeline.NewLineByJSON(testFailedJSONConf, nil, testHandlers, pipeline.WithObserver(NewObserver(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recordSpans(t, func() {
+		if _, err := line.Handle(context.Background(), &pipeline.HandleRes{Data: float64(2)}); err == nil {
+			t.Error("err is nil")
+		}
+	})
+
+	byName := map[string][]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	if n := len(byName["Line.Handle"]); n != 1 {
+		t.Fatalf("line spans: want=%v, got=%v", 1, n)
+	}
+	if n := len(byName["Pipe.Handle"]); n != 4 {
+		t.Fatalf("pipe spans: want=%v, got=%v", 4, n)
+	}
+	if n := len(byName["Parallel.branch"]); n != 2 {
+		t.Fatalf("branch spans: want=%v, got=%v", 2, n)
+	}
+
+	lineSpan := byName["Line.Handle"][0]
+	if lineSpan.Status.Code != codes.Error {
+		t.Errorf("line span status: want=%v, got=%v", codes.Error, lineSpan.Status.Code)
+	}
+	if count, _ := spanAttr(lineSpan, AttrPipesCount); count.AsInt64() != 2 {
+		t.Errorf("pipes count: want=%v, got=%v", 2, count.AsInt64())
+	}
+	if lineSpan.Parent.IsValid() {
+		t.Errorf("line span parent: want none, got=%v", lineSpan.Parent.SpanID())
+	}
+
+	spanIDs := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		spanIDs[span.SpanContext.SpanID().String()] = span
+		if span.SpanContext.TraceID() != lineSpan.SpanContext.TraceID() {
+			t.Errorf("trace id: want=%v, got=%v", lineSpan.SpanContext.TraceID(), span.SpanContext.TraceID())
+		}
+	}
+
+	topPipes := 0
+	for _, span := range byName["Pipe.Handle"] {
+		if path, _ := spanAttr(span, AttrPipePath); path.AsString() != "$[0]" && path.AsString() != "$[1]" {
+			continue
+		}
+		topPipes++
+		if span.Parent.SpanID() != lineSpan.SpanContext.SpanID() {
+			t.Errorf("parent of %v: want the line span, got=%v", span.Name, spanIDs[span.Parent.SpanID().String()].Name)
+		}
+	}
+	if topPipes != 2 {
+		t.Errorf("top pipe spans: want=%v, got=%v", 2, topPipes)
+	}
+
+	for _, span := range byName["Pipe.Handle"] {
+		desc, _ := spanAttr(span, AttrPipeDesc)
+		if desc.AsString() != "slow" {
+			continue
+		}
+
+		if timeout, _ := spanAttr(span, AttrPipeTimeout); timeout.AsInt64() != 200 {
+			t.Errorf("timeout: want=%v, got=%v", 200, timeout.AsInt64())
+		}
+		if required, _ := spanAttr(span, AttrPipeRequired); !required.AsBool() {
+			t.Errorf("required: want=%v, got=%v", true, required.AsBool())
+		}
+		if id, _ := spanAttr(span, AttrRefHandlerID); id.AsString() != "delay_1000" {
+			t.Errorf("ref handler id: want=%v, got=%v", "delay_1000", id.AsString())
+		}
+		if status, _ := spanAttr(span, AttrStatus); status.AsInt64() != int64(pipeline.HandleStatusTimeout) {
+			t.Errorf("status: want=%v, got=%v", pipeline.HandleStatusTimeout, status.AsInt64())
+		}
+		if path, _ := spanAttr(span, AttrPipePath); path.AsString() != "$[1][1]" {
+			t.Errorf("path: want=%v, got=%v", "$[1][1]", path.AsString())
+		}
+		if span.Status.Code != codes.Error {
+			t.Errorf("span status: want=%v, got=%v", codes.Error, span.Status.Code)
+		}
+
+		// Pipe.Handle -> Parallel.branch -> Pipe.Handle(parallel) -> Line.Handle
+		branch := spanIDs[span.Parent.SpanID().String()]
+		if branch.Name != "Parallel.branch" {
+			t.Fatalf("parent: want=%v, got=%v", "Parallel.branch", branch.Name)
+		}
+		if idx, _ := spanAttr(branch, AttrBranchIndex); idx.AsInt64() != 1 {
+			t.Errorf("branch index: want=%v, got=%v", 1, idx.AsInt64())
+		}
+		parallelPipe := spanIDs[branch.Parent.SpanID().String()]
+		if typ, _ := spanAttr(parallelPipe, AttrPipeType); typ.AsString() != pipeline.PipeTypeParallel {
+			t.Errorf("parent type: want=%v, got=%v", pipeline.PipeTypeParallel, typ.AsString())
+		}
+		if parallelPipe.Parent.SpanID() != lineSpan.SpanContext.SpanID() {
+			t.Errorf("line: want=%v, got=%v", lineSpan.SpanContext.SpanID(), parallelPipe.Parent.SpanID())
+		}
+		return
+	}
+	t.Error("slow pipe span not found")
+}
+
+func TestObserver_HandlerCtx(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	var handlerSpanCtxValid bool
+	handler := pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		_, span := tp.Tracer("handler").Start(ctx, "handler")
+		defer span.End()
+		handlerSpanCtxValid = span.SpanContext().IsValid()
+		return reqRes, nil
+	})
+	pipe := pipeline.Pipe{
+		Type:     pipeline.PipeTypeSingle,
+		Conf:     pipeline.PipeConf{Timeout: 20, Required: true},
+		Handler:  handler,
+		Observer: NewObserver(tp),
+	}
+
+	if _, err := pipe.Handle(context.Background(), &pipeline.HandleRes{}); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+
+	if !handlerSpanCtxValid {
+		t.Error("handler span is invalid")
+	}
+	if len(spans) != 2 {
+		t.Fatalf("spans: want=%v, got=%v", 2, len(spans))
+	}
+	// spans end in order: handler, Pipe.Handle
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("handler span is not a child of the pipe span")
+	}
+}
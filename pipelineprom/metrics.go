@@ -0,0 +1,113 @@
+// Package pipelineprom collects the Prometheus metrics of the pipeline executions by a pipeline.Observer.
+package pipelineprom
+
+import (
+	"context"
+	"time"
+
+	pipeline "github.com/Focinfi/go-pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects the Prometheus metrics of the Pipe executions keyed by the PipeConf.Desc,
+// it implements the prometheus.Collector and the pipeline.Observer.
+// Use it by pipeline.WithObserver(collector).
+type Collector struct {
+	executions       *prometheus.CounterVec
+	outcomes         *prometheus.CounterVec
+	defaultDataUsed  *prometheus.CounterVec
+	latency          *prometheus.HistogramVec
+	inFlightBranches *prometheus.GaugeVec
+}
+
+// NewCollector creates a Collector, the names of the metrics are prefixed with the namespace if it is not empty.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		executions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "pipe_executions_total",
+			Help:      "Total number of the pipe executions.",
+		}, []string{"desc"}),
+		outcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "pipe_outcomes_total",
+			Help:      "Total number of the pipe executions by the handle status.",
+		}, []string{"desc", "status"}),
+		defaultDataUsed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "pipe_default_data_used_total",
+			Help:      "Total number of the pipe executions falling back to the default data.",
+		}, []string{"desc"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "pipe_duration_seconds",
+			Help:      "Duration of the pipe executions.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"desc"}),
+		inFlightBranches: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "pipeline",
+			Name:      "parallel_in_flight_branches",
+			Help:      "Number of the running parallel branches.",
+		}, []string{"desc"}),
+	}
+}
+
+// Register registers the c into the reg.
+func (c *Collector) Register(reg prometheus.Registerer) error {
+	return reg.Register(c)
+}
+
+// Describe implements the prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.executions.Describe(ch)
+	c.outcomes.Describe(ch)
+	c.defaultDataUsed.Describe(ch)
+	c.latency.Describe(ch)
+	c.inFlightBranches.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.executions.Collect(ch)
+	c.outcomes.Collect(ch)
+	c.defaultDataUsed.Collect(ch)
+	c.latency.Collect(ch)
+	c.inFlightBranches.Collect(ch)
+}
+
+// StartLine implements the pipeline.Observer, the Lines are not collected.
+func (c *Collector) StartLine(ctx context.Context, line pipeline.Line, reqRes *pipeline.HandleRes) (context.Context, func(respRes *pipeline.HandleRes, err error)) {
+	return ctx, func(respRes *pipeline.HandleRes, err error) {}
+}
+
+// StartPipe implements the pipeline.Observer, records a execution of the pipe.
+func (c *Collector) StartPipe(ctx context.Context, pipe pipeline.Pipe, reqRes *pipeline.HandleRes) (context.Context, func(respRes *pipeline.HandleRes, err error, defaultUsed bool)) {
+	startTime := time.Now()
+	return ctx, func(respRes *pipeline.HandleRes, err error, defaultUsed bool) {
+		status := pipeline.HandleStatus(0)
+		if respRes != nil {
+			status = respRes.Status
+		}
+		desc := pipe.Conf.Desc
+		c.executions.WithLabelValues(desc).Inc()
+		c.outcomes.WithLabelValues(desc, status.String()).Inc()
+		c.latency.WithLabelValues(desc).Observe(time.Since(startTime).Seconds())
+		if defaultUsed {
+			c.defaultDataUsed.WithLabelValues(desc).Inc()
+		}
+	}
+}
+
+// StartBranch implements the pipeline.Observer, records a running parallel branch.
+func (c *Collector) StartBranch(ctx context.Context, idx int, pipe pipeline.Pipe) (context.Context, func(respRes *pipeline.HandleRes, err error)) {
+	gauge := c.inFlightBranches.WithLabelValues(pipe.Conf.Desc)
+	gauge.Inc()
+	return ctx, func(respRes *pipeline.HandleRes, err error) {
+		gauge.Dec()
+	}
+}
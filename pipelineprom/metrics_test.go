@@ -0,0 +1,162 @@
+package pipelineprom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pipeline "github.com/Focinfi/go-pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var testHandlers = pipeline.MapHandlerGetter{
+	"by_square": pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		n := reqRes.Data.(float64)
+		return &pipeline.HandleRes{Status: pipeline.HandleStatusOK, Data: n * n}, nil
+	}),
+	"failed_unknown": pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		return nil, errors.New("unknown err")
+	}),
+	"delay_1000": pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		select {
+		case <-time.After(time.Second):
+			return reqRes, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}),
+}
+
+var testMetricsJSONConf = `
+[
+    {
+        "desc": "square",
+        "ref_handler_id": "by_square",
+        "timeout": 20,
+        "required": true
+    },
+    [
+        {
+            "desc": "fallback",
+            "ref_handler_id": "failed_unknown",
+            "timeout": 20,
+            "default_data": 0
+        },
+        {
+            "desc": "slow",
+            "ref_handler_id": "delay_1000",
+            "timeout": 20,
+            "default_data": -1
+        }
+    ]
+]
+`
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector("test")
+	reg := prometheus.NewRegistry()
+	if err := collector.Register(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := pipeline.NewLineByJSON(testMetricsJSONConf, nil, testHandlers, pipeline.WithObserver(collector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := line.Handle(context.Background(), &pipeline.HandleRes{Data: float64(2)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		caseName string
+		metric   prometheus.Collector
+		want     float64
+	}{
+		{caseName: "executions of square", metric: collector.executions.WithLabelValues("square"), want: 2},
+		{caseName: "executions of the parallel", metric: collector.executions.WithLabelValues(""), want: 2},
+		{caseName: "ok of square", metric: collector.outcomes.WithLabelValues("square", "ok"), want: 2},
+		{caseName: "failed of fallback", metric: collector.outcomes.WithLabelValues("fallback", "failed"), want: 2},
+		{caseName: "timeout of slow", metric: collector.outcomes.WithLabelValues("slow", "timeout"), want: 2},
+		{caseName: "default data of square", metric: collector.defaultDataUsed.WithLabelValues("square"), want: 0},
+		{caseName: "default data of fallback", metric: collector.defaultDataUsed.WithLabelValues("fallback"), want: 2},
+		{caseName: "default data of slow", metric: collector.defaultDataUsed.WithLabelValues("slow"), want: 2},
+		{caseName: "in-flight branches of slow", metric: collector.inFlightBranches.WithLabelValues("slow"), want: 0},
+	}
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if got := testutil.ToFloat64(item.metric); got != item.want {
+				t.Errorf("want=%v, got=%v", item.want, got)
+			}
+		})
+	}
+
+	if n, err := testutil.GatherAndCount(reg, "test_pipeline_pipe_duration_seconds"); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Errorf("latency series: want=%v, got=%v", 4, n)
+	}
+}
+
+func TestCollector_Skipped(t *testing.T) {
+	collector := NewCollector("test")
+	jsonConf := `[{"desc":"skipped","ref_handler_id":"by_square","timeout":20,"default_data":0,"skip_if":"true"}]`
+	line, err := pipeline.NewLineByJSON(jsonConf, nil, testHandlers, pipeline.WithObserver(collector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := line.Handle(context.Background(), &pipeline.HandleRes{Data: float64(2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(collector.outcomes.WithLabelValues("skipped", "ok")); got != 1 {
+		t.Errorf("ok: want=%v, got=%v", 1, got)
+	}
+	if got := testutil.ToFloat64(collector.defaultDataUsed.WithLabelValues("skipped")); got != 0 {
+		t.Errorf("default data: want=%v, got=%v", 0, got)
+	}
+}
+
+func TestCollectorInFlightBranches(t *testing.T) {
+	collector := NewCollector("")
+	started := make(chan struct{})
+	blocker := pipeline.HandlerFunc(func(ctx context.Context, reqRes *pipeline.HandleRes) (*pipeline.HandleRes, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	parallel := pipeline.Parallel{
+		Pipes: []pipeline.Pipe{
+			{Type: pipeline.PipeTypeSingle, Conf: pipeline.PipeConf{Desc: "blocker", Timeout: 1000, Required: true}, Handler: blocker},
+			{Type: pipeline.PipeTypeSingle, Conf: pipeline.PipeConf{Desc: "blocker", Timeout: 1000, Required: true}, Handler: blocker},
+		},
+		Mode:     pipeline.ParallelModeAll,
+		Observer: collector,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parallel.Handle(ctx, &pipeline.HandleRes{})
+	}()
+	<-started
+	<-started
+
+	gauge := collector.inFlightBranches.WithLabelValues("blocker")
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Errorf("in-flight: want=%v, got=%v", 2, got)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("parallel not returned")
+	}
+	if got := testutil.ToFloat64(gauge); got != 0 {
+		t.Errorf("in-flight after done: want=%v, got=%v", 0, got)
+	}
+}
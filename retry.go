@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// MetaKeyRetryAttempts is the key of HandleRes.Meta to record the attempt count of a Pipe with RetryConf.
+const MetaKeyRetryAttempts = "retry_attempts"
+
+type Backoff string
+
+const (
+	BackoffFixed       Backoff = "fixed"
+	BackoffExponential Backoff = "exponential"
+	BackoffJitter      Backoff = "jitter"
+)
+
+// builtinRetryableErrs contains the built-in error sentinels can be referred by RetryConf.RetryOn,
+// the custom ones are added by WithRetryableErrs.
+// An error of the handler other than a timeout is checked as a ErrHandleFailed too, so "handle_failed" matches it.
+var builtinRetryableErrs = map[string]error{
+	"handle_timeout": ErrHandleTimeout,
+	"handle_failed":  ErrHandleFailed,
+}
+
+// RetryConf used to retry the handler of a Pipe.
+type RetryConf struct {
+	MaxAttempts    int      `json:"max_attempts"`              // including the first attempt
+	Backoff        Backoff  `json:"backoff"`                   // default BackoffFixed
+	Interval       Millis   `json:"interval"`                  // in millisecond, the base wait time between attempts
+	MaxInterval    Millis   `json:"max_interval,omitempty"`    // in millisecond, 0 means no limit
+	AttemptTimeout Millis   `json:"attempt_timeout,omitempty"` // in millisecond, 0 means using PipeConf.Timeout
+	RetryOn        []string `json:"retry_on,omitempty"`        // names of the retryable errs, empty means retrying on any error
+
+	retryOn []error // the sentinels of the RetryOn, resolved when the Pipe is created
+}
+
+// Validate validates the RetryConf.
+// The MaxAttempts must be positive.
+// The Interval, MaxInterval and AttemptTimeout must not be negative.
+// The Backoff must be empty or one of the defined Backoff.
+// The RetryOn must be names of the built-in retryable errs: "handle_timeout" and "handle_failed".
+func (rc RetryConf) Validate() error {
+	return rc.validate(builtinRetryableErrs)
+}
+
+// validate validates the RetryConf like Validate, the RetryOn must be keys of the retryableErrs.
+func (rc RetryConf) validate(retryableErrs map[string]error) error {
+	if rc.MaxAttempts < 1 {
+		return ErrRetryConfMaxAttemptsLessThanOne
+	}
+	if rc.Interval < 0 || rc.MaxInterval < 0 || rc.AttemptTimeout < 0 {
+		return ErrRetryConfNegativeDuration
+	}
+	switch rc.Backoff {
+	case "", BackoffFixed, BackoffExponential, BackoffJitter:
+	default:
+		return ErrRetryConfUnknownBackoff
+	}
+	for _, name := range rc.RetryOn {
+		if _, ok := retryableErrs[name]; !ok {
+			return ErrRetryConfUnknownRetryableErr
+		}
+	}
+	return nil
+}
+
+// resolved returns a copy of the rc with the sentinels of the RetryOn found in the retryableErrs.
+func (rc RetryConf) resolved(retryableErrs map[string]error) *RetryConf {
+	rc.retryOn = make([]error, 0, len(rc.RetryOn))
+	for _, name := range rc.RetryOn {
+		if sentinel, ok := retryableErrs[name]; ok {
+			rc.retryOn = append(rc.retryOn, sentinel)
+		}
+	}
+	return &rc
+}
+
+// retryable checks if the err can be retried,
+// the RetryOn of a rc not resolved, e.g. in a Pipe created by hand, refers to the built-in retryable errs.
+func (rc RetryConf) retryable(err error) bool {
+	if len(rc.RetryOn) == 0 {
+		return true
+	}
+	if rc.retryOn == nil {
+		rc = *rc.resolved(builtinRetryableErrs)
+	}
+	if !errors.Is(err, ErrHandleTimeout) {
+		err = fmt.Errorf("%w: %w", ErrHandleFailed, err)
+	}
+	for _, sentinel := range rc.retryOn {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the wait time before the next attempt, the attempt starts from 1.
+func (rc RetryConf) wait(attempt int) time.Duration {
+	interval := rc.Interval.Duration()
+	if rc.Backoff == BackoffExponential || rc.Backoff == BackoffJitter {
+		for i := 1; i < attempt; i++ {
+			interval *= 2
+			if rc.MaxInterval > 0 && interval >= rc.MaxInterval.Duration() {
+				break
+			}
+		}
+	}
+	if rc.MaxInterval > 0 && interval > rc.MaxInterval.Duration() {
+		interval = rc.MaxInterval.Duration()
+	}
+	if rc.Backoff == BackoffJitter && interval > 0 {
+		interval = time.Duration(rand.Int63n(int64(interval) + 1))
+	}
+	return interval
+}
+
+// handleWithRetry calls the pipe.Handler at most pipe.Conf.Retry.MaxAttempts times,
+// all the attempts and waits are limited by pipe.Conf.Timeout,
+// every attempt is limited by pipe.Conf.Retry.AttemptTimeout.
+func (pipe Pipe) handleWithRetry(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, attempts int, err error) {
+	retry := pipe.Conf.Retry
+	attemptTimeout := retry.AttemptTimeout
+	if attemptTimeout <= 0 || attemptTimeout > Millis(pipe.Conf.Timeout) {
+		attemptTimeout = Millis(pipe.Conf.Timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Millis(pipe.Conf.Timeout).Duration())
+	defer cancel()
+
+	for attempts = 1; ; attempts++ {
+		respRes, err = pipe.handleOnce(ctx, reqRes, attemptTimeout)
+		if err == nil || attempts >= retry.MaxAttempts || !retry.retryable(err) {
+			break
+		}
+		if !sleep(ctx, retry.wait(attempts)) {
+			break
+		}
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = MakeErrHandleTimeout(pipe.Conf.Desc, pipe.Conf.Timeout)
+	}
+	return respRes, attempts, err
+}
+
+// sleep waits for d, returns false if the ctx is done before that.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// metaWith returns a copy of the meta with the key set to the value.
+func metaWith(meta map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(meta)+1)
+	for k, v := range meta {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
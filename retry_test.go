@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakyHandler fails the first n calls with err, then returns the reqRes.
+func flakyHandler(n int32, err error) (Handler, *int32) {
+	var calls int32
+	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			return nil, err
+		}
+		return reqRes, nil
+	}), &calls
+}
+
+func TestRetryConf_Validate(t *testing.T) {
+	tt := []struct {
+		caseName string
+		rc       RetryConf
+		err      error
+	}{
+		{
+			caseName: "zero max attempts",
+			rc:       RetryConf{},
+			err:      ErrRetryConfMaxAttemptsLessThanOne,
+		},
+		{
+			caseName: "negative interval",
+			rc:       RetryConf{MaxAttempts: 2, Interval: -1},
+			err:      ErrRetryConfNegativeDuration,
+		},
+		{
+			caseName: "unknown backoff",
+			rc:       RetryConf{MaxAttempts: 2, Backoff: "linear"},
+			err:      ErrRetryConfUnknownBackoff,
+		},
+		{
+			caseName: "unknown retry on",
+			rc:       RetryConf{MaxAttempts: 2, RetryOn: []string{"not_found"}},
+			err:      ErrRetryConfUnknownRetryableErr,
+		},
+		{
+			caseName: "normal",
+			rc:       RetryConf{MaxAttempts: 2, Backoff: BackoffJitter, RetryOn: []string{"handle_timeout"}},
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if err := item.rc.Validate(); err != item.err {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+		})
+	}
+}
+
+func TestRetryConf_wait(t *testing.T) {
+	tt := []struct {
+		caseName string
+		rc       RetryConf
+		attempt  int
+		wait     time.Duration
+	}{
+		{
+			caseName: "fixed",
+			rc:       RetryConf{Backoff: BackoffFixed, Interval: 10},
+			attempt:  3,
+			wait:     time.Millisecond * 10,
+		},
+		{
+			caseName: "exponential",
+			rc:       RetryConf{Backoff: BackoffExponential, Interval: 10},
+			attempt:  3,
+			wait:     time.Millisecond * 40,
+		},
+		{
+			caseName: "exponential with max interval",
+			rc:       RetryConf{Backoff: BackoffExponential, Interval: 10, MaxInterval: 25},
+			attempt:  3,
+			wait:     time.Millisecond * 25,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			if wait := item.rc.wait(item.attempt); wait != item.wait {
+				t.Errorf("wait: want=%v, got=%v", item.wait, wait)
+			}
+		})
+	}
+
+	jitter := RetryConf{Backoff: BackoffJitter, Interval: 10}
+	for i := 0; i < 10; i++ {
+		if wait := jitter.wait(3); wait < 0 || wait > time.Millisecond*40 {
+			t.Errorf("jitter wait: want in [0, 40ms], got=%v", wait)
+		}
+	}
+}
+
+func TestPipe_Handle_Retry(t *testing.T) {
+	tt := []struct {
+		caseName string
+		failures int32
+		err      error
+		retry    RetryConf
+		calls    int32
+		status   HandleStatus
+	}{
+		{
+			caseName: "succeed after retries",
+			failures: 2,
+			err:      errFlaky,
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1},
+			calls:    3,
+			status:   HandleStatusOK,
+		},
+		{
+			caseName: "run out of attempts",
+			failures: 5,
+			err:      errFlaky,
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1, Backoff: BackoffExponential},
+			calls:    3,
+			status:   HandleStatusFailed,
+		},
+		{
+			caseName: "non-retryable err",
+			failures: 5,
+			err:      errFlaky,
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1, RetryOn: []string{"handle_timeout"}},
+			calls:    1,
+			status:   HandleStatusFailed,
+		},
+		{
+			caseName: "handler err as handle failed",
+			failures: 1,
+			err:      errFlaky,
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1, RetryOn: []string{"handle_failed"}},
+			calls:    2,
+			status:   HandleStatusOK,
+		},
+		{
+			caseName: "timeout not as handle failed",
+			failures: 1,
+			err:      MakeErrHandleTimeout("flaky", 1),
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1, RetryOn: []string{"handle_failed"}},
+			calls:    1,
+			status:   HandleStatusTimeout,
+		},
+		{
+			caseName: "retryable err",
+			failures: 1,
+			err:      MakeErrHandleTimeout("flaky", 1),
+			retry:    RetryConf{MaxAttempts: 3, Interval: 1, RetryOn: []string{"handle_timeout"}},
+			calls:    2,
+			status:   HandleStatusOK,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			handler, calls := flakyHandler(item.failures, item.err)
+			retry := item.retry
+			pipe := Pipe{
+				Type:    PipeTypeSingle,
+				Conf:    PipeConf{Timeout: 100, Required: false, DefaultData: -1, Retry: &retry},
+				Handler: handler,
+			}
+
+			res, err := pipe.Handle(context.Background(), &HandleRes{Data: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := atomic.LoadInt32(calls); got != item.calls {
+				t.Errorf("calls: want=%v, got=%v", item.calls, got)
+			}
+			if res.Status != item.status {
+				t.Errorf("status: want=%v, got=%v", item.status, res.Status)
+			}
+			if res.Meta[MetaKeyRetryAttempts] != int(item.calls) {
+				t.Errorf("attempts: want=%v, got=%v", item.calls, res.Meta[MetaKeyRetryAttempts])
+			}
+		})
+	}
+}
+
+func TestPipe_Handle_Retry_Timeout(t *testing.T) {
+	pipe := Pipe{
+		Type: PipeTypeSingle,
+		Conf: PipeConf{
+			Desc:     "slow",
+			Timeout:  100,
+			Required: true,
+			Retry:    &RetryConf{MaxAttempts: 10, Interval: 1, AttemptTimeout: 30},
+		},
+		Handler: delay1000,
+	}
+
+	noLeak(t, func() {
+		startTime := time.Now()
+		res, err := pipe.Handle(context.Background(), &HandleRes{})
+		if procDuration := time.Since(startTime); procDuration > time.Millisecond*150 {
+			t.Errorf("proc duration: want<=%v, got=%v", time.Millisecond*150, procDuration)
+		}
+		if !errors.Is(err, ErrHandleFailed) {
+			t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+		}
+		if res.Status != HandleStatusTimeout {
+			t.Errorf("status: want=%v, got=%v", HandleStatusTimeout, res.Status)
+		}
+		if attempts := res.Meta[MetaKeyRetryAttempts].(int); attempts < 3 || attempts > 4 {
+			t.Errorf("attempts: want in [3, 4], got=%v", attempts)
+		}
+	})
+}
+
+func TestNewLineByJSON_Retry(t *testing.T) {
+	conf := `[{"ref_handler_id":"by_square","timeout":20,"required":true,"retry":{"max_attempts":0}}]`
+	if _, err := NewLineByJSON(conf, exampleHandlerBuilderGetter, exampleHandlerGetter); !errors.Is(err, ErrRetryConfMaxAttemptsLessThanOne) {
+		t.Errorf("err: want=%v, got=%v", ErrRetryConfMaxAttemptsLessThanOne, err)
+	}
+
+	conf = `[{"ref_handler_id":"by_square","timeout":20,"required":true,"retry":{"max_attempts":2,"backoff":"jitter","interval":5,"retry_on":["handle_timeout"]}}]`
+	line, err := NewLineByJSON(conf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := line.Handle(context.Background(), &HandleRes{Data: float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(HandleRes{Status: HandleStatusOK, Meta: map[string]interface{}{MetaKeyRetryAttempts: 1}, Data: 4}, res); !ok {
+		t.Error("res diff:\n", text)
+	}
+}
+
+func TestNewLineByJSON_WithRetryableErrs(t *testing.T) {
+	conf := `[{"ref_handler_id":"flaky","timeout":100,"required":true,"retry":{"max_attempts":3,"interval":1,"retry_on":["flaky"]}}]`
+	handler, calls := flakyHandler(1, errFlaky)
+	handlers := MapHandlerGetter{"flaky": handler}
+	if _, err := NewLineByJSON(conf, nil, handlers); !errors.Is(err, ErrRetryConfUnknownRetryableErr) {
+		t.Errorf("err: want=%v, got=%v", ErrRetryConfUnknownRetryableErr, err)
+	}
+
+	line, err := NewLineByJSON(conf, nil, handlers, WithRetryableErrs(map[string]error{"flaky": errFlaky}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := line.Handle(context.Background(), &HandleRes{Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("calls: want=%v, got=%v", 2, got)
+	}
+}
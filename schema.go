@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// LineSchema is the JSON Schema of the JSON conf of a Line, it is the content of the line.schema.json,
+// editors can use the file to validate the conf files.
+//
+//go:embed line.schema.json
+var LineSchema string
+
+// ConfSchemaHandlerBuilder is a HandlerBuilder declaring the JSON Schema of its conf,
+// the PipeConf.HandlerBuilderConf is validated against the schema before calling Build.
+type ConfSchemaHandlerBuilder interface {
+	HandlerBuilder
+	ConfSchema() string
+}
+
+// WithConfSchema wraps the builder as a ConfSchemaHandlerBuilder declaring the schema.
+func WithConfSchema(builder HandlerBuilder, schema string) ConfSchemaHandlerBuilder {
+	return confSchemaHandlerBuilder{HandlerBuilder: builder, schema: schema}
+}
+
+type confSchemaHandlerBuilder struct {
+	HandlerBuilder
+	schema string
+}
+
+func (b confSchemaHandlerBuilder) ConfSchema() string {
+	return b.schema
+}
+
+// compiledSchemas caches the compiled schemas keyed by their text.
+var compiledSchemas sync.Map
+
+func compileSchema(schema string) (*jsonschema.Schema, error) {
+	if compiled, ok := compiledSchemas.Load(schema); ok {
+		return compiled.(*jsonschema.Schema), nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBuilderConfSchemaInvalid, err)
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("urn:go-pipeline:conf", doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBuilderConfSchemaInvalid, err)
+	}
+	compiled, err := compiler.Compile("urn:go-pipeline:conf")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBuilderConfSchemaInvalid, err)
+	}
+	compiledSchemas.Store(schema, compiled)
+	return compiled, nil
+}
+
+// validateBuilderConf validates the conf against the schema of the builder if it is a ConfSchemaHandlerBuilder,
+// a nil conf is validated as an empty object.
+func validateBuilderConf(builder HandlerBuilder, conf map[string]interface{}) error {
+	schemaBuilder, ok := builder.(ConfSchemaHandlerBuilder)
+	if !ok {
+		return nil
+	}
+	schema, err := compileSchema(schemaBuilder.ConfSchema())
+	if err != nil {
+		return err
+	}
+
+	if conf == nil {
+		conf = map[string]interface{}{}
+	}
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBuilderConfSchemaMismatch, err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(strings.NewReader(string(b)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBuilderConfSchemaMismatch, err)
+	}
+	if err := schema.Validate(instance); err != nil {
+		return fmt.Errorf("%w: %s", ErrBuilderConfSchemaMismatch, strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+func TestLineSchema(t *testing.T) {
+	schema, err := compileSchema(LineSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		caseName string
+		jsonConf string
+		valid    bool
+	}{
+		{caseName: "normal", jsonConf: testJSONConf, valid: true},
+		{caseName: "nested", jsonConf: testNestedJSONConf, valid: true},
+		{caseName: "switch", jsonConf: testSwitchJSONConf, valid: true},
+		{caseName: "loader", jsonConf: testLoaderJSONConf, valid: true},
+		{
+			caseName: "retry and middlewares",
+			jsonConf: `[{"handler_builder_name":"delay","handler_builder_conf":{"delay":"10ms"},"timeout":"1.5s","required":true,
+				"retry":{"max_attempts":3,"backoff":"jitter","interval":"10ms","retry_on":["handle_timeout"]},"middlewares":["log"]}]`,
+			valid: true,
+		},
+		{
+			caseName: "map and quorum",
+			jsonConf: `[{"type":"map","pipe":{"type":"parallel","mode":"quorum","quorum":1,"pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true}]}}]`,
+			valid:    true,
+		},
+		{
+			caseName: "data mapping",
+			jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true,"input_path":"$.n","output_path":"$.square"}]`,
+			valid:    true,
+		},
+		{
+			caseName: "meta",
+			jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true,"meta_policy":"replace","meta_keys":["k"]}]`,
+			valid:    true,
+		},
+		{
+			caseName: "expressions",
+			jsonConf: `[{"handler_builder_name":"transform","handler_builder_conf":{"expr":"data * 2"},"timeout":20,"required":true,"skip_if":"data > 10"},
+				{"type":"switch","cases":[{"when":{"expr":"data > 1"},"pipes":[]}]}]`,
+			valid: true,
+		},
+		{caseName: "unknown meta policy", jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true,"meta_policy":"drop"}]`},
+		{caseName: "bad output path", jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true,"output_path":"square"}]`},
+		{caseName: "not array", jsonConf: `{"ref_handler_id":"by_square","timeout":20,"required":true}`},
+		{caseName: "unknown field", jsonConf: `[{"ref_handler_id":"by_square","timeout":20,"required":true,"timout":20}]`},
+		{caseName: "no timeout", jsonConf: `[{"ref_handler_id":"by_square","required":true}]`},
+		{caseName: "bad timeout", jsonConf: `[{"ref_handler_id":"by_square","timeout":"20","required":true}]`},
+		{caseName: "no handler", jsonConf: `[{"timeout":20,"required":true}]`},
+		{caseName: "no default data", jsonConf: `[{"ref_handler_id":"by_square","timeout":20}]`},
+		{caseName: "unknown type", jsonConf: `[{"type":"loop"}]`},
+		{caseName: "nested array in parallel", jsonConf: `[[[{"ref_handler_id":"by_square","timeout":20,"required":true}]]]`},
+		{caseName: "unknown mode", jsonConf: `[{"type":"parallel","mode":"random","pipes":[]}]`},
+		{caseName: "quorum without quorum", jsonConf: `[{"type":"parallel","mode":"quorum","pipes":[]}]`},
+		{caseName: "bad condition", jsonConf: `[{"type":"switch","cases":[{"when":{"path":"$.data","op":"like"},"pipes":[]}]}]`},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			instance, err := jsonschema.UnmarshalJSON(strings.NewReader(item.jsonConf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = schema.Validate(instance)
+			if item.valid && err != nil {
+				t.Errorf("want valid, got=%v", err)
+			}
+			if !item.valid && err == nil {
+				t.Error("want invalid, got valid")
+			}
+		})
+	}
+}
+
+func TestConfSchemaHandlerBuilder(t *testing.T) {
+	built := 0
+	builder := WithConfSchema(HandlerBuilderFunc(func(conf map[string]interface{}) (Handler, error) {
+		built++
+		return bySquare, nil
+	}), `{
+		"type": "object",
+		"properties": {"delay": {"type": "string"}},
+		"required": ["delay"],
+		"additionalProperties": false
+	}`)
+	builders := MapHandlerBuilderGetter{
+		"schema":     builder,
+		"bad_schema": WithConfSchema(builder, `{"type": 1}`),
+	}
+
+	tt := []struct {
+		caseName string
+		jsonConf string
+		err      error
+	}{
+		{
+			caseName: "valid",
+			jsonConf: `[{"handler_builder_name":"schema","handler_builder_conf":{"delay":"10ms"},"timeout":20,"required":true}]`,
+		},
+		{
+			caseName: "missing",
+			jsonConf: `[{"handler_builder_name":"schema","timeout":20,"required":true}]`,
+			err:      ErrBuilderConfSchemaMismatch,
+		},
+		{
+			caseName: "wrong type",
+			jsonConf: `[{"handler_builder_name":"schema","handler_builder_conf":{"delay":10},"timeout":20,"required":true}]`,
+			err:      ErrBuilderConfSchemaMismatch,
+		},
+		{
+			caseName: "bad schema",
+			jsonConf: `[{"handler_builder_name":"bad_schema","handler_builder_conf":{"delay":"10ms"},"timeout":20,"required":true}]`,
+			err:      ErrBuilderConfSchemaInvalid,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			built = 0
+			_, err := NewLineByJSON(item.jsonConf, builders, exampleHandlerGetter)
+			if item.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if built != 1 {
+					t.Errorf("built: want=%v, got=%v", 1, built)
+				}
+				return
+			}
+
+			if !errors.Is(err, item.err) || !errors.Is(err, ErrBuildHandlerFailed) {
+				t.Errorf("err: want=%v, got=%v", item.err, err)
+			}
+			if !strings.HasPrefix(err.Error(), "$[0]: ") {
+				t.Errorf("err: want prefixed with the path, got=%v", err)
+			}
+			if built != 0 {
+				t.Errorf("built: want=%v, got=%v", 0, built)
+			}
+		})
+	}
+}
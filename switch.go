@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// SwitchCase runs the Line when the When matches.
+type SwitchCase struct {
+	When Condition `json:"when"`
+	Line *Line     `json:"line"`
+}
+
+// Switch routes the HandleRes to the Line of the first matched SwitchCase,
+// or to the Default if none of them matches.
+type Switch struct {
+	Cases   []SwitchCase `json:"cases"`
+	Default *Line        `json:"default"`
+}
+
+// Handle implements the Handler.
+// Handles the given reqRes with the Line of the first matched case,
+// uses the switch.Default when nothing matches,
+// returns ErrHandleFailed wrapping ErrSwitchNoCaseMatched if the switch.Default is nil.
+func (s Switch) Handle(ctx context.Context, reqRes *HandleRes) (respRes *HandleRes, err error) {
+	for _, c := range s.Cases {
+		if c.When.Match(reqRes) {
+			return c.Line.Handle(ctx, reqRes)
+		}
+	}
+	if s.Default != nil {
+		return s.Default.Handle(ctx, reqRes)
+	}
+
+	e := fmt.Errorf("%w: %w", ErrHandleFailed, ErrSwitchNoCaseMatched)
+	res := &HandleRes{
+		Status:  HandleStatusFailed,
+		Message: e.Error(),
+	}
+	if reqRes != nil {
+		res.Meta = reqRes.Meta
+		res.Data = reqRes.Data
+	}
+	return res, e
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testSwitchJSONConf = `
+[
+    {
+        "type":"switch",
+        "desc":"by-kind",
+        "cases":[
+            {
+                "when":{"path":"$.meta.kind","op":"eq","value":"square"},
+                "pipes":[{"ref_handler_id":"by_square","timeout":20,"required":true}]
+            },
+            {
+                "when":{"any":[
+                    {"path":"$.meta.kind","op":"eq","value":"cubic"},
+                    {"path":"$.data","op":"gt","value":100}
+                ]},
+                "pipes":[{"ref_handler_id":"by_cubic","timeout":20,"required":true}]
+            }
+        ],
+        "default":[]
+    }
+]
+`
+
+func TestSwitch_Handle(t *testing.T) {
+	line, err := NewLineByJSON(testSwitchJSONConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.Pipes[0].Type != PipeTypeSwitch {
+		t.Errorf("type: want=%v, got=%v", PipeTypeSwitch, line.Pipes[0].Type)
+	}
+
+	tt := []struct {
+		caseName string
+		reqRes   *HandleRes
+		data     interface{}
+	}{
+		{
+			caseName: "first case",
+			reqRes:   &HandleRes{Meta: map[string]interface{}{"kind": "square"}, Data: float64(2)},
+			data:     4,
+		},
+		{
+			caseName: "second case",
+			reqRes:   &HandleRes{Meta: map[string]interface{}{"kind": "cubic"}, Data: float64(2)},
+			data:     8,
+		},
+		{
+			caseName: "default",
+			reqRes:   &HandleRes{Data: float64(2)},
+			data:     2,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			res, err := line.Handle(context.Background(), item.reqRes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.data, res.Data); !ok {
+				t.Error("data diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestSwitch_Handle_NoCaseMatched(t *testing.T) {
+	conf := `[{"type":"switch","cases":[{"when":{"path":"$.data","op":"exists"},"pipes":[]}]}]`
+	line, err := NewLineByJSON(conf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := line.Handle(context.Background(), &HandleRes{})
+	if !errors.Is(err, ErrSwitchNoCaseMatched) {
+		t.Errorf("err: want=%v, got=%v", ErrSwitchNoCaseMatched, err)
+	}
+	if !errors.Is(err, ErrHandleFailed) {
+		t.Errorf("err: want=%v, got=%v", ErrHandleFailed, err)
+	}
+	if res.Status != HandleStatusFailed {
+		t.Errorf("status: want=%v, got=%v", HandleStatusFailed, res.Status)
+	}
+}
+
+func TestNewLineByJSON_Switch_ErrPath(t *testing.T) {
+	tt := []struct {
+		caseName string
+		jsonConf string
+		path     string
+		err      error
+	}{
+		{
+			caseName: "bad condition",
+			jsonConf: `[{"type":"switch","cases":[{"when":{"path":"$.data","op":"exists"},"pipes":[]},{"when":{"all":[{"path":"$.data","op":"like"}]},"pipes":[]}]}]`,
+			path:     "$[0].cases[1].when.all[0]",
+			err:      ErrConditionUnknownOp,
+		},
+		{
+			caseName: "bad case pipe",
+			jsonConf: `[{"type":"switch","cases":[{"when":{"path":"$.data","op":"exists"},"pipes":[{"ref_handler_id":"not_found","timeout":20,"required":true}]}]}]`,
+			path:     "$[0].cases[0].pipes[0]",
+			err:      ErrRefHandlerNotFound,
+		},
+		{
+			caseName: "bad default pipe",
+			jsonConf: `[{"type":"switch","cases":[],"default":[{"type":"unknown"}]}]`,
+			path:     "$[0].default[0]",
+			err:      ErrPipeConfUnknownType,
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			_, err := NewLineByJSON(item.jsonConf, exampleHandlerBuilderGetter, exampleHandlerGetter)
+			if !errors.Is(err, item.err) {
+				t.Fatalf("err: want=%v, got=%v", item.err, err)
+			}
+			if !strings.HasPrefix(err.Error(), item.path+":") {
+				t.Errorf("err path: want=%v, got=%v", item.path, err)
+			}
+		})
+	}
+}
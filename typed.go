@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+)
+
+// TypedHandler handles the HandleRes.Data as an In and returns an Out as the new HandleRes.Data.
+type TypedHandler[In, Out any] interface {
+	HandleTyped(ctx context.Context, in In) (Out, error)
+}
+
+// TypedHandlerFunc type is an adapter to allow the use of ordinary functions as typed handlers.
+// If f is a function with the appropriate signature, TypedHandlerFunc[In, Out](f) is a TypedHandler that calls f.
+type TypedHandlerFunc[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// HandleTyped calls f(ctx, in).
+func (f TypedHandlerFunc[In, Out]) HandleTyped(ctx context.Context, in In) (Out, error) {
+	return f(ctx, in)
+}
+
+// FromTyped adapts the typed as a Handler.
+// The HandleRes.Data is converted into an In by ConvertData, the Meta is passed through,
+// returns a ErrDataTypeMismatch error without calling the typed if the conversion failed.
+func FromTyped[In, Out any](typed TypedHandler[In, Out]) Handler {
+	return HandlerFunc(func(ctx context.Context, reqRes *HandleRes) (*HandleRes, error) {
+		var (
+			data interface{}
+			meta map[string]interface{}
+		)
+		if reqRes != nil {
+			data, meta = reqRes.Data, reqRes.Meta
+		}
+
+		in, err := ConvertData[In](data)
+		if err != nil {
+			return nil, err
+		}
+		out, err := typed.HandleTyped(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return &HandleRes{Meta: meta, Data: out}, nil
+	})
+}
+
+// ToTyped adapts the handler as a TypedHandler.
+// The in is passed as the HandleRes.Data, the Data of the response is converted into an Out by ConvertData.
+func ToTyped[In, Out any](handler Handler) TypedHandler[In, Out] {
+	return TypedHandlerFunc[In, Out](func(ctx context.Context, in In) (Out, error) {
+		var out Out
+		res, err := handler.Handle(ctx, &HandleRes{Data: in})
+		if err != nil {
+			return out, err
+		}
+		if res == nil {
+			return out, nil
+		}
+		return ConvertData[Out](res.Data)
+	})
+}
+
+// ConvertData converts the data into a T.
+// The data is returned directly if it is a T, otherwise it is converted like DecodeConf,
+// e.g. a float64 decoded from JSON into an int, a map[string]interface{} into a struct.
+// Returns the errors of all the mismatched fields with their paths in one error, e.g. "data.user.age",
+// every one of them wraps ErrDataTypeMismatch.
+func ConvertData[T any](data interface{}) (T, error) {
+	if t, ok := data.(T); ok {
+		return t, nil
+	}
+
+	var t T
+	decoder := &confDecoder{errRequired: ErrDataTypeMismatch, errInvalid: ErrDataTypeMismatch}
+	decoder.decode("data", data, reflect.ValueOf(&t).Elem())
+	if len(decoder.errs) > 0 {
+		return t, decoder.errs
+	}
+	return t, nil
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	Name string   `json:"name" required:"true"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags"`
+}
+
+func TestConvertData(t *testing.T) {
+	tt := []struct {
+		caseName string
+		convert  func() (interface{}, error)
+		want     interface{}
+		errPaths []string
+	}{
+		{
+			caseName: "same type",
+			convert:  func() (interface{}, error) { return ConvertData[float64](float64(2)) },
+			want:     float64(2),
+		},
+		{
+			caseName: "number",
+			convert:  func() (interface{}, error) { return ConvertData[int](float64(2)) },
+			want:     2,
+		},
+		{
+			caseName: "struct",
+			convert: func() (interface{}, error) {
+				return ConvertData[testUser](map[string]interface{}{"name": "foo", "age": float64(18), "tags": []interface{}{"a"}})
+			},
+			want: testUser{Name: "foo", Age: 18, Tags: []string{"a"}},
+		},
+		{
+			caseName: "pointer of struct",
+			convert: func() (interface{}, error) {
+				return ConvertData[*testUser](map[string]interface{}{"name": "foo"})
+			},
+			want: &testUser{Name: "foo"},
+		},
+		{
+			caseName: "list of struct",
+			convert: func() (interface{}, error) {
+				return ConvertData[[]testUser]([]interface{}{map[string]interface{}{"name": "foo"}})
+			},
+			want: []testUser{{Name: "foo"}},
+		},
+		{
+			caseName: "string to number",
+			convert:  func() (interface{}, error) { return ConvertData[float64]("2") },
+			errPaths: []string{"data: "},
+		},
+		{
+			caseName: "bad fields",
+			convert: func() (interface{}, error) {
+				return ConvertData[[]testUser]([]interface{}{map[string]interface{}{"age": "18", "tags": []interface{}{1}}})
+			},
+			errPaths: []string{"data[0].name: ", "data[0].age: ", "data[0].tags[0]: "},
+		},
+	}
+
+	for _, item := range tt {
+		t.Run(item.caseName, func(t *testing.T) {
+			got, err := item.convert()
+			if item.errPaths != nil {
+				if !errors.Is(err, ErrDataTypeMismatch) {
+					t.Fatalf("err: want=%v, got=%v", ErrDataTypeMismatch, err)
+				}
+				for _, path := range item.errPaths {
+					if !strings.Contains(err.Error(), path) {
+						t.Errorf("err: want %q, got=%v", path, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := diff(item.want, got); !ok {
+				t.Error("diff:\n", text)
+			}
+		})
+	}
+}
+
+func TestFromTyped(t *testing.T) {
+	handler := FromTyped(TypedHandlerFunc[testUser, string](func(ctx context.Context, in testUser) (string, error) {
+		if in.Age < 0 {
+			return "", errUnknown
+		}
+		return in.Name, nil
+	}))
+	meta := map[string]interface{}{"k": "v"}
+
+	res, err := handler.Handle(context.Background(), &HandleRes{Meta: meta, Data: map[string]interface{}{"name": "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := diff(&HandleRes{Meta: meta, Data: "foo"}, res); !ok {
+		t.Error("diff:\n", text)
+	}
+
+	if _, err := handler.Handle(context.Background(), &HandleRes{Data: map[string]interface{}{"name": "foo", "age": -1}}); err != errUnknown {
+		t.Errorf("err: want=%v, got=%v", errUnknown, err)
+	}
+
+	// a required Pipe fails instead of panicking
+	pipe := Pipe{Type: PipeTypeSingle, Conf: PipeConf{Timeout: 20, Required: true}, Handler: handler}
+	if _, err := pipe.Handle(context.Background(), &HandleRes{Data: "foo"}); !errors.Is(err, ErrDataTypeMismatch) || !errors.Is(err, ErrHandleFailed) {
+		t.Errorf("err: want=%v, got=%v", ErrDataTypeMismatch, err)
+	}
+}
+
+func TestToTyped(t *testing.T) {
+	square := ToTyped[int, int](bySquare)
+	out, err := square.HandleTyped(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != 9 {
+		t.Errorf("want=%v, got=%v", 9, out)
+	}
+
+	if _, err := ToTyped[int, string](bySquare).HandleTyped(context.Background(), 3); !errors.Is(err, ErrDataTypeMismatch) {
+		t.Errorf("err: want=%v, got=%v", ErrDataTypeMismatch, err)
+	}
+	if _, err := ToTyped[int, int](failedUnknown).HandleTyped(context.Background(), 3); err != errUnknown {
+		t.Errorf("err: want=%v, got=%v", errUnknown, err)
+	}
+}
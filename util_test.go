@@ -2,7 +2,9 @@ package pipeline
 
 import (
 	"encoding/json"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/nsf/jsondiff"
 )
@@ -43,3 +45,24 @@ func TestDiff(t *testing.T) {
 		t.Log("diff:\n", text)
 	}
 }
+
+// noLeak runs f and fails t if goroutines started by f are still alive
+// after a short grace period.
+func noLeak(t *testing.T, f func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	f()
+
+	deadline := time.Now().Add(time.Millisecond * 500)
+	for {
+		after := runtime.NumGoroutine()
+		if after <= before {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("goroutine leaked: before=%v, after=%v", before, after)
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}